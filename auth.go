@@ -0,0 +1,261 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+const (
+	MetaData_Principal = "principal"
+)
+
+var (
+	Business_Code_Unauthorized = "401000"
+)
+
+const (
+	PrincipalType_JWT    = "jwt"
+	PrincipalType_APIKey = "apiKey"
+	PrincipalType_Token  = "token"
+)
+
+// Principal 认证通过的调用方信息
+type Principal struct {
+	Type    string         `json:"type"`
+	Subject string         `json:"subject"`
+	Claims  map[string]any `json:"claims"`
+}
+
+// Authenticator 凭证校验
+type Authenticator interface {
+	Authenticate(credential string) (principal *Principal, err error)
+}
+
+// AuthenticatorFunc 函数形式的凭证校验，如校验 login_token
+type AuthenticatorFunc func(credential string) (principal *Principal, err error)
+
+func (fn AuthenticatorFunc) Authenticate(credential string) (principal *Principal, err error) {
+	return fn(credential)
+}
+
+// APIKeyAuthenticator API Key 校验，Keys 为 key->调用方标识
+type APIKeyAuthenticator struct {
+	Keys map[string]string
+}
+
+func (a APIKeyAuthenticator) Authenticate(credential string) (principal *Principal, err error) {
+	subject, ok := a.Keys[credential]
+	if !ok {
+		return nil, errors.New("invalid api key")
+	}
+	principal = &Principal{
+		Type:    PrincipalType_APIKey,
+		Subject: subject,
+	}
+	return principal, nil
+}
+
+// CredentialExtractor 从请求中提取凭证，不存在时返回空
+type CredentialExtractor func(message *RequestMessage) (credential string)
+
+// CredentialFromHeader 从请求头提取凭证，prefix 不为空时要求以其开头(不区分大小写)并去除，如 CredentialFromHeader("Authorization", "Bearer ")
+func CredentialFromHeader(name string, prefix string) CredentialExtractor {
+	return func(message *RequestMessage) (credential string) {
+		value := message.GetHeader(name)
+		if prefix == "" {
+			return value
+		}
+		if len(value) < len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+			return ""
+		}
+		return strings.TrimSpace(value[len(prefix):])
+	}
+}
+
+func CredentialFromQuery(name string) CredentialExtractor {
+	return func(message *RequestMessage) (credential string) {
+		u, err := url.Parse(message.URL)
+		if err != nil {
+			return ""
+		}
+		return u.Query().Get(name)
+	}
+}
+
+// CredentialFromBody 从请求体字段提取凭证(json或表单)，json支持以.分隔的路径，如 login_token、_head._token
+func CredentialFromBody(field string) CredentialExtractor {
+	return func(message *RequestMessage) (credential string) {
+		body := message.GetRaw()
+		if len(body) == 0 {
+			return ""
+		}
+		if strings.Contains(message.GetHeader("Content-Type"), ContentTypeForm) {
+			values, err := url.ParseQuery(string(body))
+			if err != nil {
+				return ""
+			}
+			return values.Get(field)
+		}
+		var value any
+		err := json.Unmarshal(body, &value)
+		if err != nil {
+			return ""
+		}
+		for _, key := range strings.Split(field, ".") {
+			m, ok := value.(map[string]any)
+			if !ok {
+				return ""
+			}
+			value = m[key]
+		}
+		if value == nil {
+			return ""
+		}
+		return cast.ToString(value)
+	}
+}
+
+// AuthConfig 服务端认证配置
+type AuthConfig struct {
+	Extractors    []CredentialExtractor // 按顺序提取，取第一个非空凭证
+	Authenticator Authenticator
+	BusinessCode  string // 认证失败返回的业务码，默认 Business_Code_Unauthorized
+}
+
+// RequestMiddleAuth 服务端认证中间件，认证通过后将 *Principal 存入 MetaData(MetaData_Principal)
+func RequestMiddleAuth(config AuthConfig) HandlerFunc[RequestMessage] {
+	if config.BusinessCode == "" {
+		config.BusinessCode = Business_Code_Unauthorized
+	}
+	return func(message *RequestMessage) (err error) {
+		credential := ""
+		for _, extractor := range config.Extractors {
+			credential = extractor(message)
+			if credential != "" {
+				break
+			}
+		}
+		if credential == "" {
+			return NewCodeError(config.BusinessCode, "credential missing").WithHttpStatus(http.StatusUnauthorized)
+		}
+		if config.Authenticator == nil {
+			return NewCodeError(config.BusinessCode, "authenticator not configured").WithHttpStatus(http.StatusUnauthorized)
+		}
+		principal, err := config.Authenticator.Authenticate(credential)
+		if err != nil {
+			return NewCodeError(config.BusinessCode, err.Error()).WithHttpStatus(http.StatusUnauthorized)
+		}
+		message.SetMetaData(MetaData_Principal, principal)
+		return message.Next()
+	}
+}
+
+// GetPrincipal 获取认证通过的调用方信息
+func (m *RequestMessage) GetPrincipal() (principal *Principal, ok bool) {
+	v, exists := m.MetaData.Get(MetaData_Principal)
+	if !exists {
+		return nil, false
+	}
+	principal, ok = v.(*Principal)
+	return principal, ok
+}
+
+// TokenSource 客户端令牌来源
+type TokenSource interface {
+	Token() (token string, err error)
+	Refresh() (token string, err error) // 服务端返回401时调用
+}
+
+// CachedTokenSource 缓存令牌，首次使用或刷新时调用 FetchFn 获取，并发安全
+type CachedTokenSource struct {
+	FetchFn func() (token string, err error)
+	mutex   sync.Mutex
+	token   string
+}
+
+func (s *CachedTokenSource) Token() (token string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.token != "" {
+		return s.token, nil
+	}
+	return s.fetch()
+}
+
+func (s *CachedTokenSource) Refresh() (token string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.fetch()
+}
+
+func (s *CachedTokenSource) fetch() (token string, err error) {
+	token, err = s.FetchFn()
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	return token, nil
+}
+
+// RequestMiddleBearerToken 客户端添加 Authorization: Bearer 令牌
+func RequestMiddleBearerToken(source TokenSource) HandlerFunc[RequestMessage] {
+	return func(message *RequestMessage) (err error) {
+		token, err := source.Token()
+		if err != nil {
+			return err
+		}
+		message.Headers.Set("Authorization", "Bearer "+token)
+		return message.Next()
+	}
+}
+
+// RequestMiddleAPIKey 客户端添加 API Key 请求头，请求头登记为凭证请求头，缓存、请求合并按 key 区分
+func RequestMiddleAPIKey(header string, key string) HandlerFunc[RequestMessage] {
+	RegisterCredentialHeader(header)
+	return func(message *RequestMessage) (err error) {
+		message.Headers.Set(header, key)
+		return message.Next()
+	}
+}
+
+// ResponseMiddleRefreshToken 客户端收到401时刷新令牌并重试一次，需与 RequestMiddleBearerToken 配合使用
+func ResponseMiddleRefreshToken(source TokenSource) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		message.WrapDo(func(next DoFunc) DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				response, err := next(req)
+				if err != nil || response.StatusCode != http.StatusUnauthorized {
+					return response, err
+				}
+				if req.GetBody == nil && req.Body != nil && req.Body != http.NoBody { // 请求体无法重放
+					return response, nil
+				}
+				token, err := source.Refresh()
+				if err != nil {
+					return response, nil // 刷新失败时返回原始401响应
+				}
+				response.Body.Close()
+				retry := req.Clone(req.Context())
+				if req.GetBody != nil {
+					retry.Body, err = req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+				}
+				retry.Header.Set("Authorization", "Bearer "+token)
+				if requestMessage, ok := message.GetRequestMessage(); ok {
+					requestMessage.Headers.Set("Authorization", "Bearer "+token)
+					_ = requestMessage.SetDuplicateRequest(retry)
+				}
+				return next(retry)
+			}
+		})
+		return message.Next()
+	}
+}
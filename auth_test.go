@@ -0,0 +1,117 @@
+package apihttpprotocol
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestJWTAuthenticatorRS256(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","n":"%s","e":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+	)
+	set, err := NewJWKSet([]byte(jwks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+	payload, _ := json.Marshal(map[string]any{"sub": "user-1", "aud": []string{"order"}, "exp": time.Now().Add(time.Minute).Unix()})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	token := signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	authenticator := JWTAuthenticator{JWKS: set, Audience: "order"}
+	principal, err := authenticator.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != "user-1" {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+	_, err = JWTAuthenticator{JWKS: set, Audience: "user"}.Authenticate(token)
+	if err == nil {
+		t.Fatal("audience mismatch should fail")
+	}
+}
+
+var authTestSecret = []byte("secret")
+
+// newAuthTestServer 校验 Bearer 令牌或请求体 login_token 的测试服务
+func newAuthTestServer(t *testing.T) *httptest.Server {
+	protoFn := testProtoFn(func(p *ServerProtocol) {
+		p.Request().AddMiddleware(RequestMiddleAuth(AuthConfig{
+			Extractors: []CredentialExtractor{
+				CredentialFromHeader("Authorization", "Bearer "),
+				CredentialFromBody("login_token"),
+			},
+			Authenticator: JWTAuthenticator{HmacSecret: authTestSecret},
+		}))
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+	})
+	return newTestServer(t, func(engine *gin.Engine) {
+		engine.POST("/order", NewGinHander(protoFn, func(in map[string]any) (out map[string]any, err error) {
+			return in, nil
+		}))
+	})
+}
+
+func TestAuthRefreshToken(t *testing.T) {
+	server := newAuthTestServer(t)
+	fetchCount := 0
+	source := &CachedTokenSource{FetchFn: func() (token string, err error) {
+		fetchCount++
+		exp := time.Now().Add(time.Minute)
+		if fetchCount == 1 {
+			exp = time.Now().Add(-time.Minute) // 首次获取的令牌已过期
+		}
+		return SignJWTHmac(map[string]any{"sub": "svc", "exp": exp.Unix()}, "HS256", authTestSecret)
+	}}
+	client := NewClientProtocol("POST", server.URL+"/order")
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeJson)
+	client.Request().AddMiddleware(RequestMiddleBearerToken(source))
+	client.Response().AddMiddleware(ResponseMiddleRefreshToken(source), ResponseMiddleCodeMessageForClient)
+	var out map[string]any
+	err := client.Do(map[string]any{"orderId": "12"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetchCount != 2 || out["orderId"] != "12" {
+		t.Fatalf("unexpected fetchCount:%d,out:%v", fetchCount, out)
+	}
+}
+
+func TestAuthLoginTokenBody(t *testing.T) {
+	server := newAuthTestServer(t)
+	token, err := SignJWTHmac(map[string]any{"sub": "svc", "exp": time.Now().Add(time.Minute).Unix()}, "HS256", authTestSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientProtocol("POST", server.URL+"/order")
+	client.SetLog(LogIgnore{})
+	client.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+	var out map[string]any
+	err = client.Do(map[string]any{"orderId": "13", "login_token": token}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["orderId"] != "13" {
+		t.Fatalf("unexpected out:%v", out)
+	}
+}
@@ -0,0 +1,337 @@
+package apihttpprotocol
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MetaData_CacheStatus = "cache_status" // 缓存命中情况，取值 CacheStatus_Xxx
+)
+
+const (
+	CacheStatus_Miss        = "miss"
+	CacheStatus_Hit         = "hit"
+	CacheStatus_Revalidated = "revalidated" // 缓存过期，上游返回304后继续使用
+)
+
+// CachedResponse 缓存的原始响应，保存原始body以便 GoStructRef 仍通过中间件链解码
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	ETag       string
+	ExpiresAt  time.Time
+	Vary       map[string]string // 响应 Vary 指定的请求头及缓存时的取值
+}
+
+// Fresh 是否在有效期内
+func (c CachedResponse) Fresh(now time.Time) bool {
+	return now.Before(c.ExpiresAt)
+}
+
+// varyMatch 请求头与缓存时响应 Vary 指定的请求头取值一致
+func (c CachedResponse) varyMatch(req *http.Request) bool {
+	for name, value := range c.Vary {
+		if strings.Join(req.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// responseVary 解析响应 Vary，包含 * 时不可缓存
+func responseVary(req *http.Request, response *http.Response) (vary map[string]string, cacheable bool) {
+	for _, value := range response.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			if vary == nil {
+				vary = map[string]string{}
+			}
+			vary[name] = strings.Join(req.Header.Values(name), ",")
+		}
+	}
+	return vary, true
+}
+
+func (c CachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.StatusCode, http.StatusText(c.StatusCode)),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+// CacheStore 缓存存储，可替换为redis等实现
+type CacheStore interface {
+	Get(key string) (cached *CachedResponse, ok bool)
+	Set(key string, cached *CachedResponse)
+	Delete(key string)
+}
+
+type lruEntry struct {
+	key    string
+	cached *CachedResponse
+}
+
+// LRUCacheStore 内存LRU缓存
+type LRUCacheStore struct {
+	capacity int
+	mutex    sync.Mutex
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+func NewLRUCacheStore(capacity int) *LRUCacheStore {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &LRUCacheStore{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (s *LRUCacheStore) Get(key string) (cached *CachedResponse, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	element, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.ll.MoveToFront(element)
+	return element.Value.(*lruEntry).cached, true
+}
+
+func (s *LRUCacheStore) Set(key string, cached *CachedResponse) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.items[key]; ok {
+		element.Value.(*lruEntry).cached = cached
+		s.ll.MoveToFront(element)
+		return
+	}
+	s.items[key] = s.ll.PushFront(&lruEntry{key: key, cached: cached})
+	for s.ll.Len() > s.capacity {
+		oldest := s.ll.Back()
+		s.ll.Remove(oldest)
+		delete(s.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (s *LRUCacheStore) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.items[key]; ok {
+		s.ll.Remove(element)
+		delete(s.items, key)
+	}
+}
+
+var defaultCacheStore = NewLRUCacheStore(1000)
+
+var (
+	credentialHeaderMutex sync.RWMutex
+	credentialHeaders     = []string{"Authorization", "Cookie"}
+)
+
+// RegisterCredentialHeader 登记携带凭证的请求头，缓存、请求合并时始终参与key计算，避免不同凭证的请求共用响应；RequestMiddleAPIKey 会自动登记其请求头
+func RegisterCredentialHeader(headers ...string) {
+	credentialHeaderMutex.Lock()
+	defer credentialHeaderMutex.Unlock()
+	for _, header := range headers {
+		header = http.CanonicalHeaderKey(header)
+		if !slices.Contains(credentialHeaders, header) {
+			credentialHeaders = append(credentialHeaders, header)
+		}
+	}
+}
+
+// CredentialHeaders 已登记的凭证请求头，默认 Authorization、Cookie
+func CredentialHeaders() []string {
+	credentialHeaderMutex.RLock()
+	defer credentialHeaderMutex.RUnlock()
+	return append([]string{}, credentialHeaders...)
+}
+
+// withCredentialHeaders 请求头列表追加凭证请求头，extra 为配置中额外指定的凭证请求头
+func withCredentialHeaders(varyHeaders []string, extra []string) []string {
+	headers := append(append([]string{}, varyHeaders...), extra...)
+	return append(headers, CredentialHeaders()...)
+}
+
+type CacheConfig struct {
+	Store             CacheStore    // 默认全局共享的内存LRU
+	VaryHeaders       []string      // 参与缓存key计算的请求头，如 Accept
+	CredentialHeaders []string      // 额外的凭证请求头，与 CredentialHeaders() 一起始终参与缓存key计算
+	DefaultTTL        time.Duration // 上游未返回 max-age 时的缓存时长，0表示仅在有ETag时缓存并每次校验
+	Methods           []string      // 允许缓存的方法，默认 GET、HEAD
+}
+
+// cacheControl 解析 Cache-Control
+type cacheControl struct {
+	noStore bool
+	noCache bool
+	maxAge  time.Duration
+	hasAge  bool
+}
+
+func parseCacheControl(value string) (cc cacheControl) {
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		name, arg, _ := strings.Cut(directive, "=")
+		switch name {
+		case "no-store":
+			cc.noStore = true
+		case "no-cache":
+			cc.noCache = true
+		case "max-age":
+			seconds, err := strconv.Atoi(strings.Trim(arg, `"`))
+			if err == nil {
+				cc.maxAge = time.Duration(seconds) * time.Second
+				cc.hasAge = true
+			}
+		}
+	}
+	return cc
+}
+
+// CacheKey 按方法、URL、指定请求头及请求体hash生成缓存key
+func CacheKey(req *http.Request, varyHeaders []string) (key string, err error) {
+	w := sha256.New()
+	fmt.Fprintf(w, "%s\n%s\n", req.Method, req.URL.String())
+	for _, name := range varyHeaders {
+		fmt.Fprintf(w, "%s:%s\n", http.CanonicalHeaderKey(name), strings.Join(req.Header.Values(name), ","))
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		_, err = io.Copy(w, body)
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(w.Sum(nil)), nil
+}
+
+// ResponseMiddleCache 客户端响应缓存，遵循 Cache-Control/ETag，过期后携带 If-None-Match 校验，304 时复用缓存
+func ResponseMiddleCache(config CacheConfig) HandlerFunc[ResponseMessage] {
+	if config.Store == nil {
+		config.Store = defaultCacheStore
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{http.MethodGet, http.MethodHead}
+	}
+	return func(message *ResponseMessage) (err error) {
+		message.WrapDo(func(next DoFunc) DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				if !cacheableMethod(config.Methods, req.Method) || parseCacheControl(req.Header.Get("Cache-Control")).noStore {
+					return next(req)
+				}
+				key, err := CacheKey(req, withCredentialHeaders(config.VaryHeaders, config.CredentialHeaders)) // 请求时读取，登记顺序不影响
+				if err != nil {
+					return nil, err
+				}
+				now := time.Now()
+				cached, ok := config.Store.Get(key)
+				if ok && !cached.varyMatch(req) { // 响应 Vary 指定的请求头不一致，不能复用
+					ok = false
+				}
+				if ok && cached.Fresh(now) {
+					message.SetMetaData(MetaData_CacheStatus, CacheStatus_Hit)
+					return cached.toResponse(req), nil
+				}
+				outReq := req
+				if ok && cached.ETag != "" {
+					outReq = req.Clone(req.Context())
+					outReq.Header.Set("If-None-Match", cached.ETag)
+				}
+				response, err := next(outReq)
+				if err != nil {
+					return nil, err
+				}
+				if ok && response.StatusCode == http.StatusNotModified {
+					response.Body.Close()
+					refreshed := *cached
+					refreshed.ExpiresAt = cacheExpiresAt(parseCacheControl(response.Header.Get("Cache-Control")), config.DefaultTTL, now)
+					config.Store.Set(key, &refreshed)
+					message.SetMetaData(MetaData_CacheStatus, CacheStatus_Revalidated)
+					return refreshed.toResponse(req), nil
+				}
+				message.SetMetaData(MetaData_CacheStatus, CacheStatus_Miss)
+				if response.StatusCode != http.StatusOK {
+					return response, nil
+				}
+				cc := parseCacheControl(response.Header.Get("Cache-Control"))
+				etag := response.Header.Get("ETag")
+				vary, varyCacheable := responseVary(req, response)
+				if cc.noStore || !varyCacheable || (etag == "" && !cc.hasAge && config.DefaultTTL <= 0) {
+					config.Store.Delete(key)
+					return response, nil
+				}
+				body, err := io.ReadAll(response.Body)
+				response.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				response.Body = io.NopCloser(bytes.NewReader(body))
+				config.Store.Set(key, &CachedResponse{
+					StatusCode: response.StatusCode,
+					Header:     response.Header.Clone(),
+					Body:       body,
+					ETag:       etag,
+					ExpiresAt:  cacheExpiresAt(cc, config.DefaultTTL, now),
+					Vary:       vary,
+				})
+				return response, nil
+			}
+		})
+		return message.Next()
+	}
+}
+
+func cacheableMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheExpiresAt no-cache 时立即过期(每次校验)，优先使用 max-age，否则使用 DefaultTTL
+func cacheExpiresAt(cc cacheControl, defaultTTL time.Duration, now time.Time) time.Time {
+	if cc.noCache {
+		return now
+	}
+	if cc.hasAge {
+		return now.Add(cc.maxAge)
+	}
+	return now.Add(defaultTTL)
+}
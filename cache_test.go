@@ -0,0 +1,153 @@
+package apihttpprotocol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestResponseCache(t *testing.T) {
+	var hits, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/fresh" {
+			w.Header().Set("Cache-Control", "max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"0","message":"success","data":{"name":"config"}}`))
+	}))
+	defer server.Close()
+
+	config := CacheConfig{Store: NewLRUCacheStore(10)}
+	call := func(path string) (out map[string]any, status any) {
+		client := NewClientProtocol("GET", server.URL+path)
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(ResponseMiddleCache(config), ResponseMiddleCodeMessageForClient)
+		err := client.Do(nil, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, _ = client.Response().MetaData.Get(MetaData_CacheStatus)
+		return out, status
+	}
+
+	for i, want := range []string{CacheStatus_Miss, CacheStatus_Hit} {
+		out, status := call("/fresh")
+		if status != want || out["name"] != "config" {
+			t.Fatalf("call %d: status:%v,out:%v", i, status, out)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("fresh response should be served from cache,hits:%d", hits.Load())
+	}
+
+	for i, want := range []string{CacheStatus_Miss, CacheStatus_Revalidated} {
+		out, status := call("/revalidate")
+		if status != want || out["name"] != "config" {
+			t.Fatalf("call %d: status:%v,out:%v", i, status, out)
+		}
+	}
+	if notModified.Load() != 1 {
+		t.Fatalf("expected one 304,got %d", notModified.Load())
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/star" {
+			w.Header().Set("Vary", "*")
+		} else {
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"0","message":"success","data":{"user":"` + r.Header.Get("Authorization") + `","lang":"` + r.Header.Get("Accept-Language") + `"}}`))
+	}))
+	defer server.Close()
+
+	config := CacheConfig{Store: NewLRUCacheStore(10)}
+	call := func(path string, authorization string, language string) (out map[string]any, status any) {
+		client := NewClientProtocol("GET", server.URL+path)
+		client.SetLog(LogIgnore{})
+		client.SetHeader("Authorization", authorization)
+		client.SetHeader("Accept-Language", language)
+		client.Response().AddMiddleware(ResponseMiddleCache(config), ResponseMiddleCodeMessageForClient)
+		err := client.Do(nil, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, _ = client.Response().MetaData.Get(MetaData_CacheStatus)
+		return out, status
+	}
+
+	cases := []struct {
+		authorization string
+		language      string
+		status        string
+	}{
+		{"a", "en", CacheStatus_Miss},
+		{"a", "en", CacheStatus_Hit},
+		{"b", "en", CacheStatus_Miss}, // 凭证不同不共用缓存
+		{"a", "zh", CacheStatus_Miss}, // Vary 请求头不同
+		{"a", "zh", CacheStatus_Hit},
+	}
+	for i, c := range cases {
+		out, status := call("/user", c.authorization, c.language)
+		if status != c.status || out["user"] != c.authorization || out["lang"] != c.language {
+			t.Fatalf("call %d: status:%v,out:%v", i, status, out)
+		}
+	}
+	hits.Store(0)
+	call("/star", "a", "en")
+	call("/star", "a", "en")
+	if hits.Load() != 2 {
+		t.Fatalf("Vary:* should not be cached,hits:%d", hits.Load())
+	}
+}
+
+func TestResponseCacheCredentialHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"0","message":"success","data":{"key":"` + r.Header.Get("X-Api-Key") + r.Header.Get("X-Tenant-Token") + `"}}`))
+	}))
+	defer server.Close()
+
+	config := CacheConfig{Store: NewLRUCacheStore(10), CredentialHeaders: []string{"X-Tenant-Token"}}
+	call := func(path string, middleware HandlerFuncRequestMessage) (out map[string]any) {
+		client := NewClientProtocol("GET", server.URL+path)
+		client.SetLog(LogIgnore{})
+		client.Request().AddMiddleware(middleware)
+		client.Response().AddMiddleware(ResponseMiddleCache(config), ResponseMiddleCodeMessageForClient)
+		err := client.Do(nil, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	for _, key := range []string{"key-a", "key-b"} { // RequestMiddleAPIKey 的请求头自动参与缓存key计算
+		if out := call("/api-key", RequestMiddleAPIKey("X-Api-Key", key)); out["key"] != key {
+			t.Fatalf("api key %s got cached response of another key:%v", key, out)
+		}
+	}
+	for _, token := range []string{"tenant-a", "tenant-b"} { // CacheConfig.CredentialHeaders 指定的凭证请求头
+		tenant := func(message *RequestMessage) (err error) {
+			message.Headers.Set("X-Tenant-Token", token)
+			return message.Next()
+		}
+		if out := call("/tenant", tenant); out["key"] != token {
+			t.Fatalf("tenant %s got cached response of another tenant:%v", token, out)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package apihttpprotocol
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState 熔断器状态
+type CircuitState int
+
+const (
+	CircuitState_Closed CircuitState = iota
+	CircuitState_Open
+	CircuitState_HalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitState_Closed:
+		return "closed"
+	case CircuitState_Open:
+		return "open"
+	case CircuitState_HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// CircuitKeyFunc 熔断维度
+type CircuitKeyFunc func(message *RequestMessage) (key string)
+
+// CircuitKeyByHost 按上游host熔断
+func CircuitKeyByHost(message *RequestMessage) (key string) {
+	u, err := url.Parse(message.URL)
+	if err != nil {
+		return message.URL
+	}
+	return u.Host
+}
+
+// CircuitKeyByEndpoint 按接口(方法+host+路径)熔断
+func CircuitKeyByEndpoint(message *RequestMessage) (key string) {
+	u, err := url.Parse(message.URL)
+	if err != nil {
+		return fmt.Sprintf("%s %s", message.Method, message.URL)
+	}
+	return fmt.Sprintf("%s %s%s", message.Method, u.Host, u.Path)
+}
+
+type CircuitBreakerConfig struct {
+	Window               time.Duration  // 滚动统计窗口，默认10秒
+	MinRequests          int            // 窗口内请求数达到该值才计算失败率，默认20
+	FailureRatio         float64        // 5xx及网络错误比例阈值，默认0.5
+	BusinessFailureRatio float64        // 业务失败(http 200 且带业务码的错误)比例阈值，0表示业务失败不触发熔断
+	OpenTimeout          time.Duration  // 熔断后进入半开状态的等待时间，默认30秒
+	HalfOpenMaxRequests  int            // 半开状态允许的探测请求数，全部成功后关闭熔断，默认1
+	KeyFn                CircuitKeyFunc // 默认 CircuitKeyByHost
+	OnStateChange        func(key string, from CircuitState, to CircuitState)
+}
+
+const circuitWindowBuckets = 10
+
+type circuitBucket struct {
+	start            time.Time
+	total            int
+	serverFailures   int
+	businessFailures int
+}
+
+type circuit struct {
+	state      CircuitState
+	generation int // 状态变更时递增，丢弃过期的统计结果
+	openedAt   time.Time
+	buckets    [circuitWindowBuckets]circuitBucket
+	probing    int // 半开状态进行中的探测请求数
+	probeOk    int
+}
+
+// CircuitBreaker 熔断器，需全局共享(不要在每次请求时创建)
+type CircuitBreaker struct {
+	config   CircuitBreakerConfig
+	mutex    sync.Mutex
+	circuits map[string]*circuit
+}
+
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.Window <= 0 {
+		config.Window = 10 * time.Second
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 20
+	}
+	if config.FailureRatio <= 0 {
+		config.FailureRatio = 0.5
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+	if config.HalfOpenMaxRequests <= 0 {
+		config.HalfOpenMaxRequests = 1
+	}
+	if config.KeyFn == nil {
+		config.KeyFn = CircuitKeyByHost
+	}
+	return &CircuitBreaker{
+		config:   config,
+		circuits: map[string]*circuit{},
+	}
+}
+
+// State 获取当前状态
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		return CircuitState_Closed
+	}
+	if c.state == CircuitState_Open && time.Since(c.openedAt) >= b.config.OpenTimeout {
+		return CircuitState_HalfOpen
+	}
+	return c.state
+}
+
+type circuitOutcome int
+
+const (
+	circuitOutcome_Ignore circuitOutcome = iota
+	circuitOutcome_Success
+	circuitOutcome_ServerFailure
+	circuitOutcome_BusinessFailure
+)
+
+// classifyCircuitOutcome 区分 5xx/网络错误/无法解析的200响应 与 业务失败，4xx 等调用方错误不计入
+func classifyCircuitOutcome(message *ResponseMessage, err error) circuitOutcome {
+	if err == nil {
+		return circuitOutcome_Success
+	}
+	var responseError ResponseError
+	if errors.As(err, &responseError) {
+		if responseError.HttpCode >= http.StatusInternalServerError {
+			return circuitOutcome_ServerFailure
+		}
+		return circuitOutcome_Ignore
+	}
+	if message.HttpCode == 0 { // 未拿到响应，网络错误
+		return circuitOutcome_ServerFailure
+	}
+	if message.HttpCode == http.StatusOK {
+		var codeErr ErrorWithCode
+		if errors.As(err, &codeErr) { // 带业务码(RemoteError 等)才是业务失败
+			return circuitOutcome_BusinessFailure
+		}
+		return circuitOutcome_ServerFailure // 解码、信封校验失败，响应不可用
+	}
+	return circuitOutcome_Ignore
+}
+
+type circuitTransition struct {
+	from CircuitState
+	to   CircuitState
+}
+
+func (b *CircuitBreaker) setState(c *circuit, state CircuitState, now time.Time, transitions *[]circuitTransition) {
+	if c.state == state {
+		return
+	}
+	*transitions = append(*transitions, circuitTransition{from: c.state, to: state})
+	c.state = state
+	c.generation++
+	c.probing = 0
+	c.probeOk = 0
+	c.buckets = [circuitWindowBuckets]circuitBucket{}
+	if state == CircuitState_Open {
+		c.openedAt = now
+	}
+}
+
+// before 请求前检查，返回是否放行及当前代次
+func (b *CircuitBreaker) before(key string) (generation int, transitions []circuitTransition, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	now := time.Now()
+	if c.state == CircuitState_Open && now.Sub(c.openedAt) >= b.config.OpenTimeout {
+		b.setState(c, CircuitState_HalfOpen, now, &transitions)
+	}
+	switch c.state {
+	case CircuitState_Open:
+		return c.generation, transitions, errors.WithMessagef(ErrCircuitOpen, "key:%s", key)
+	case CircuitState_HalfOpen:
+		if c.probing >= b.config.HalfOpenMaxRequests {
+			return c.generation, transitions, errors.WithMessagef(ErrCircuitOpen, "key:%s,half-open probing", key)
+		}
+		c.probing++
+	}
+	return c.generation, transitions, nil
+}
+
+// after 记录请求结果
+func (b *CircuitBreaker) after(key string, generation int, outcome circuitOutcome) (transitions []circuitTransition) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	c := b.circuits[key]
+	if c == nil || c.generation != generation {
+		return nil
+	}
+	now := time.Now()
+	if c.state == CircuitState_HalfOpen {
+		switch outcome {
+		case circuitOutcome_ServerFailure:
+			b.setState(c, CircuitState_Open, now, &transitions)
+		case circuitOutcome_BusinessFailure:
+			if b.config.BusinessFailureRatio > 0 {
+				b.setState(c, CircuitState_Open, now, &transitions)
+				return transitions
+			}
+			fallthrough
+		default:
+			c.probeOk++
+			if c.probeOk >= b.config.HalfOpenMaxRequests {
+				b.setState(c, CircuitState_Closed, now, &transitions)
+			}
+		}
+		return transitions
+	}
+	if outcome == circuitOutcome_Ignore {
+		return nil
+	}
+	bucketSize := b.config.Window / circuitWindowBuckets
+	index := int(now.UnixNano()/int64(bucketSize)) % circuitWindowBuckets
+	bucketStart := now.Truncate(bucketSize)
+	bucket := &c.buckets[index]
+	if !bucket.start.Equal(bucketStart) { // 过期的桶重新计数
+		*bucket = circuitBucket{start: bucketStart}
+	}
+	bucket.total++
+	switch outcome {
+	case circuitOutcome_ServerFailure:
+		bucket.serverFailures++
+	case circuitOutcome_BusinessFailure:
+		bucket.businessFailures++
+	}
+	total, serverFailures, businessFailures := 0, 0, 0
+	for _, item := range c.buckets {
+		if now.Sub(item.start) >= b.config.Window {
+			continue
+		}
+		total += item.total
+		serverFailures += item.serverFailures
+		businessFailures += item.businessFailures
+	}
+	if total < b.config.MinRequests {
+		return nil
+	}
+	tripped := float64(serverFailures)/float64(total) >= b.config.FailureRatio
+	if b.config.BusinessFailureRatio > 0 && float64(businessFailures)/float64(total) >= b.config.BusinessFailureRatio {
+		tripped = true
+	}
+	if tripped {
+		b.setState(c, CircuitState_Open, now, &transitions)
+	}
+	return transitions
+}
+
+// ResponseMiddleCircuitBreaker 客户端熔断中间件，需放在 ResponseMiddleCodeMessageForClient 之前才能统计业务失败
+func ResponseMiddleCircuitBreaker(breaker *CircuitBreaker) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		requestMessage, ok := message.GetRequestMessage()
+		if !ok {
+			return message.Next()
+		}
+		key := breaker.config.KeyFn(requestMessage)
+		generation, transitions, err := breaker.before(key)
+		breaker.onTransitions(message, key, transitions)
+		if err != nil {
+			return err
+		}
+		err = message.Next()
+		transitions = breaker.after(key, generation, classifyCircuitOutcome(message, err))
+		breaker.onTransitions(message, key, transitions)
+		return err
+	}
+}
+
+// onTransitions 记录状态变更日志并回调 OnStateChange(在锁外执行，回调中可调用 State)
+func (b *CircuitBreaker) onTransitions(message *ResponseMessage, key string, transitions []circuitTransition) {
+	for _, transition := range transitions {
+		message.GetLog().Warn(fmt.Sprintf("requestId:%s,circuit breaker key:%s,state:%s -> %s", message.GetRequestId(), key, transition.from, transition.to))
+		if b.config.OnStateChange != nil {
+			b.config.OnStateChange(key, transition.from, transition.to)
+		}
+	}
+}
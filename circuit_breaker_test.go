@@ -0,0 +1,82 @@
+package apihttpprotocol
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"0","message":"success","data":"ok"}`))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	changes := make([]string, 0)
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		MinRequests: 2,
+		OpenTimeout: 20 * time.Millisecond,
+		OnStateChange: func(key string, from CircuitState, to CircuitState) {
+			changes = append(changes, to.String())
+		},
+	})
+	call := func() error {
+		client := NewClientProtocol("GET", server.URL)
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(ResponseMiddleCircuitBreaker(breaker), ResponseMiddleCodeMessageForClient)
+		var out string
+		return client.Do(nil, &out)
+	}
+	for i := 0; i < 2; i++ {
+		_ = call()
+	}
+	if state := breaker.State(u.Host); state != CircuitState_Open {
+		t.Fatalf("expected open,got %s", state)
+	}
+	if err := call(); !errors.Is(err, ErrCircuitOpen) || hits.Load() != 2 {
+		t.Fatalf("open circuit should fail fast,err:%v,hits:%d", err, hits.Load())
+	}
+	healthy.Store(true)
+	time.Sleep(30 * time.Millisecond)
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+	if state := breaker.State(u.Host); state != CircuitState_Closed {
+		t.Fatalf("expected closed,got %s", state)
+	}
+	if len(changes) != 3 || changes[0] != "open" || changes[1] != "half-open" || changes[2] != "closed" {
+		t.Fatalf("unexpected state changes %v", changes)
+	}
+}
+
+func TestClassifyCircuitOutcome(t *testing.T) {
+	message := &ResponseMessage{}
+	message.HttpCode = http.StatusOK
+	cases := []struct {
+		err  error
+		want circuitOutcome
+	}{
+		{nil, circuitOutcome_Success},
+		{&RemoteError{Code: "400100"}, circuitOutcome_BusinessFailure},
+		{NewCodeError("400100", "bad request"), circuitOutcome_BusinessFailure},
+		{errors.New("invalid character '<' looking for beginning of value"), circuitOutcome_ServerFailure}, // 如网关返回html
+	}
+	for _, c := range cases {
+		if got := classifyCircuitOutcome(message, c.err); got != c.want {
+			t.Fatalf("err:%v,want %d,got %d", c.err, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// apisdkgen 根据 OpenAPI 文档(RouteRegistry.OpenAPI 生成)输出基于 ClientProtocol 的 Go 客户端 SDK，可用于 go generate：
+//
+//	//go:generate go run github.com/suifengpiao14/apihttpprotocol/cmd/apisdkgen -spec openapi.yaml -package orderclient -out client_gen.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/suifengpiao14/apihttpprotocol"
+)
+
+func main() {
+	spec := flag.String("spec", "", "OpenAPI 文档路径或 http 地址(json/yaml)")
+	pkg := flag.String("package", "client", "生成代码的包名")
+	clientName := flag.String("client", "Client", "客户端类型名")
+	out := flag.String("out", "", "输出文件，为空时输出到标准输出")
+	timeout := flag.Duration("timeout", 30*time.Second, "通过 http 获取文档的超时时间")
+	flag.Parse()
+	if *spec == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	err := run(*spec, *pkg, *clientName, *out, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apisdkgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(spec string, pkg string, clientName string, out string, timeout time.Duration) (err error) {
+	b, err := readSpec(spec, timeout)
+	if err != nil {
+		return err
+	}
+	doc, err := apihttpprotocol.LoadOpenAPIDocument(b)
+	if err != nil {
+		return err
+	}
+	code, err := apihttpprotocol.GenerateGoClient(doc, apihttpprotocol.GoClientConfig{Package: pkg, ClientName: clientName})
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(out, code, 0o644)
+}
+
+func readSpec(spec string, timeout time.Duration) (b []byte, err error) {
+	if !strings.HasPrefix(spec, "http://") && !strings.HasPrefix(spec, "https://") {
+		return os.ReadFile(spec)
+	}
+	client := &http.Client{Timeout: timeout} // 文档服务无响应时不会一直阻塞 go generate
+	response, err := client.Get(spec)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", spec, response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
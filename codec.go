@@ -0,0 +1,382 @@
+package apihttpprotocol
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ContentTypeXml      = "application/xml"
+	ContentTypeForm     = "application/x-www-form-urlencoded"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Codec 编解码接口，根据 Content-Type/Accept 选择具体实现
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var ErrCodecNotSupport = errors.New("codec not support this value")
+
+var (
+	codecPool      = map[string]Codec{}
+	codecPoolMutex sync.RWMutex
+)
+
+// RegisterCodec 注册编解码器，aliases 为额外匹配的Content-Type，同名覆盖(可用于替换默认json实现)
+func RegisterCodec(codec Codec, aliases ...string) {
+	codecPoolMutex.Lock()
+	defer codecPoolMutex.Unlock()
+	for _, contentType := range append([]string{codec.ContentType()}, aliases...) {
+		codecPool[normalizeMediaType(contentType)] = codec
+	}
+}
+
+// GetCodec 根据Content-Type获取编解码器，xxx+json、xxx+xml 分别匹配json、xml
+func GetCodec(contentType string) (codec Codec, ok bool) {
+	mediaType := normalizeMediaType(contentType)
+	if mediaType == "" {
+		return nil, false
+	}
+	codecPoolMutex.RLock()
+	defer codecPoolMutex.RUnlock()
+	codec, ok = codecPool[mediaType]
+	if ok {
+		return codec, true
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		codec, ok = codecPool[ContentTypeJson]
+	case strings.HasSuffix(mediaType, "+xml"):
+		codec, ok = codecPool[ContentTypeXml]
+	}
+	return codec, ok
+}
+
+// GetCodecByAccept 根据Accept头获取编解码器，按q值从高到低匹配已注册的编解码器，*/* 不参与匹配。
+// xml 只在客户端首选(q值最高)且严格高于json时使用，xxx+xml(如浏览器默认的 application/xhtml+xml)不匹配xml，避免浏览器访问时输出xml
+func GetCodecByAccept(accept string) (codec Codec, ok bool) {
+	type acceptItem struct {
+		mediaType string
+		q         float64
+		codec     Codec
+	}
+	items := make([]acceptItem, 0)
+	maxQ, jsonQ := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, exists := params["q"]; exists {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > maxQ {
+			maxQ = q
+		}
+		if strings.HasSuffix(mediaType, "/*") {
+			continue
+		}
+		codec, ok = getCodecByAcceptMediaType(mediaType)
+		if !ok {
+			continue
+		}
+		if codec.ContentType() == ContentTypeJson && q > jsonQ {
+			jsonQ = q
+		}
+		items = append(items, acceptItem{mediaType: mediaType, q: q, codec: codec})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+	for _, item := range items {
+		if item.codec.ContentType() == ContentTypeXml && (item.q < maxQ || item.q <= jsonQ) {
+			continue
+		}
+		return item.codec, true
+	}
+	return nil, false
+}
+
+// getCodecByAcceptMediaType 只匹配已注册的类型及 xxx+json
+func getCodecByAcceptMediaType(mediaType string) (codec Codec, ok bool) {
+	mediaType = normalizeMediaType(mediaType)
+	codecPoolMutex.RLock()
+	defer codecPoolMutex.RUnlock()
+	codec, ok = codecPool[mediaType]
+	if ok {
+		return codec, true
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		codec, ok = codecPool[ContentTypeJson]
+	}
+	return codec, ok
+}
+
+// DefaultCodec 未指定Content-Type时使用的编解码器
+func DefaultCodec() Codec {
+	codec, _ := GetCodec(ContentTypeJson)
+	return codec
+}
+
+func normalizeMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	return strings.ToLower(mediaType)
+}
+
+// JsonCodec json编解码器，可替换MarshalFn、UnmarshalFn 以使用 sonic、go-json 等高性能实现
+type JsonCodec struct {
+	MarshalFn   func(v any) ([]byte, error)
+	UnmarshalFn func(data []byte, v any) error
+}
+
+func (c JsonCodec) ContentType() string {
+	return ContentTypeJson
+}
+
+func (c JsonCodec) Marshal(v any) ([]byte, error) {
+	if msg, ok := v.(proto.Message); ok { // proto 消息使用protojson，保留字段名及枚举格式
+		return ProtoJSONMarshalOptions.Marshal(msg)
+	}
+	if c.MarshalFn != nil {
+		return c.MarshalFn(v)
+	}
+	return json.Marshal(v)
+}
+
+func (c JsonCodec) Unmarshal(data []byte, v any) error {
+	if msg, ok := protoMessageOf(v); ok {
+		return ProtoJSONUnmarshalOptions.Unmarshal(data, msg)
+	}
+	if c.UnmarshalFn != nil {
+		return c.UnmarshalFn(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// SetJsonCodec 替换默认json实现，如 SetJsonCodec(sonic.Marshal, sonic.Unmarshal)
+func SetJsonCodec(marshalFn func(v any) ([]byte, error), unmarshalFn func(data []byte, v any) error) {
+	RegisterCodec(JsonCodec{MarshalFn: marshalFn, UnmarshalFn: unmarshalFn})
+}
+
+type XmlCodec struct{}
+
+func (c XmlCodec) ContentType() string {
+	return ContentTypeXml
+}
+
+func (c XmlCodec) Marshal(v any) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (c XmlCodec) Unmarshal(data []byte, v any) error {
+	return xml.Unmarshal(data, v)
+}
+
+// FormCodec 表单编解码器，字段名使用json tag
+type FormCodec struct{}
+
+func (c FormCodec) ContentType() string {
+	return ContentTypeForm
+}
+
+func (c FormCodec) Marshal(v any) (b []byte, err error) {
+	values := url.Values{}
+	switch ref := v.(type) {
+	case url.Values:
+		values = ref
+	case map[string]string:
+		for k, val := range ref {
+			values.Set(k, val)
+		}
+	default:
+		rv := reflect.Indirect(reflect.ValueOf(v))
+		switch rv.Kind() {
+		case reflect.Struct:
+			encodeFormStruct(rv, values)
+		case reflect.Map:
+			iter := rv.MapRange()
+			for iter.Next() {
+				k := cast.ToString(iter.Key().Interface())
+				values[k] = append(values[k], formStrings(iter.Value())...)
+			}
+		default:
+			err = errors.WithMessagef(ErrCodecNotSupport, "FormCodec.Marshal(%T)", v)
+			return nil, err
+		}
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (c FormCodec) Unmarshal(data []byte, v any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	return decodeFormValues(values, v)
+}
+
+// encodeFormStruct 按 json tag 编码结构体字段，嵌入结构体字段展开，omitempty 的零值跳过
+func encodeFormStruct(rv reflect.Value, values url.Values) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if field.Anonymous && name == "" {
+			embedded := fv
+			if embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() || !field.IsExported() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				encodeFormStruct(embedded, values)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && fv.IsZero() {
+			continue
+		}
+		values[name] = append(values[name], formStrings(fv)...)
+	}
+}
+
+// formStrings 字段值转换为表单值，切片、数组按同名参数重复输出，time.Time 等实现 encoding.TextMarshaler 的类型使用其文本格式
+func formStrings(v reflect.Value) (out []string) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := marshaler.MarshalText()
+		if err == nil {
+			return []string{string(b)}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return []string{cast.ToString(v.Interface())}
+		}
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, formStrings(v.Index(i))...)
+		}
+		return out
+	case reflect.Bool:
+		return []string{strconv.FormatBool(v.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return []string{strconv.FormatInt(v.Int(), 10)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []string{strconv.FormatUint(v.Uint(), 10)}
+	case reflect.Float32, reflect.Float64:
+		return []string{strconv.FormatFloat(v.Float(), 'f', -1, 64)}
+	case reflect.String:
+		return []string{v.String()}
+	}
+	return []string{cast.ToString(v.Interface())}
+}
+
+// formValueHook 切片字段取全部同名参数，其余字段只取第一个
+func formValueHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	values, ok := data.([]string)
+	if !ok {
+		return data, nil
+	}
+	for to.Kind() == reflect.Pointer {
+		to = to.Elem()
+	}
+	if to.Kind() == reflect.Slice || to.Kind() == reflect.Array {
+		return values, nil
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+	return values[0], nil
+}
+
+// decodeFormValues 将表单值解码到v，切片字段取全部同名参数，其余字段只取第一个，数值等类型由字符串弱类型转换，time.Time 等按 encoding.TextUnmarshaler 解析
+func decodeFormValues(values url.Values, v any) (err error) {
+	m := map[string]any{}
+	for k, val := range values {
+		m[k] = val
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(formValueHook, mapstructure.TextUnmarshallerHookFunc()),
+		WeaklyTypedInput: true,
+		Squash:           true, // 嵌入结构体(如 PageInput)字段展开，与json一致
+		Result:           v,
+		TagName:          "json",
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(m)
+}
+
+// ProtobufCodec protobuf编解码器，值必须实现 proto.Message(解码时也支持 **pb.Xxx)
+type ProtobufCodec struct{}
+
+func (c ProtobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (c ProtobufCodec) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.WithMessagef(ErrCodecNotSupport, "ProtobufCodec.Marshal(%T)", v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (c ProtobufCodec) Unmarshal(data []byte, v any) error {
+	msg, ok := protoMessageOf(v)
+	if !ok {
+		return errors.WithMessagef(ErrCodecNotSupport, "ProtobufCodec.Unmarshal(%T)", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+func init() {
+	RegisterCodec(JsonCodec{})
+	RegisterCodec(XmlCodec{}, "text/xml")
+	RegisterCodec(FormCodec{})
+	RegisterCodec(ProtobufCodec{}, "application/protobuf")
+}
@@ -0,0 +1,158 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetCodecByAccept(t *testing.T) {
+	cases := map[string]string{
+		"application/xml;q=0.5, application/json":  ContentTypeJson,
+		"application/xml, application/json;q=0.5":  ContentTypeXml,
+		"application/xml":                          ContentTypeXml,
+		"application/xml, application/json":        ContentTypeJson, // q值相同时优先json
+		"application/problem+json":                 ContentTypeJson,
+		"application/x-protobuf, application/json": ContentTypeProtobuf,
+	}
+	for accept, want := range cases {
+		codec, ok := GetCodecByAccept(accept)
+		if !ok || codec.ContentType() != want {
+			t.Fatalf("accept %s: want %s,got %v", accept, want, codec)
+		}
+	}
+	for _, accept := range []string{
+		"*/*",
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", // 浏览器默认
+		"application/atom+xml",
+	} {
+		if codec, ok := GetCodecByAccept(accept); ok {
+			t.Fatalf("accept %s: should fall back to default,got %s", accept, codec.ContentType())
+		}
+	}
+}
+
+func TestFormCodec(t *testing.T) {
+	type page struct {
+		PageInput
+		OrderId string    `json:"orderId"`
+		Ids     []int     `json:"ids"`
+		Since   time.Time `json:"since"`
+		Until   time.Time `json:"until,omitempty"`
+	}
+	since := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	b, err := FormCodec{}.Marshal(page{PageInput: PageInput{PageSize: 10, PageIndex: 1}, OrderId: "12", Ids: []int{1, 2}, Since: since})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ids=1&ids=2&orderId=12&pageIndex=1&pageSize=10&since=2024-05-01T08%3A30%3A00Z"; string(b) != want {
+		t.Fatalf("want %s,got %s", want, b)
+	}
+	var dst page
+	err = FormCodec{}.Unmarshal(b, &dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.PageSize != 10 || dst.PageIndex != 1 || dst.OrderId != "12" || len(dst.Ids) != 2 || dst.Ids[1] != 2 || !dst.Since.Equal(since) {
+		t.Fatalf("unexpected %+v", dst)
+	}
+}
+
+func TestXmlNegotiation(t *testing.T) {
+	type order struct {
+		OrderId string `json:"orderId" xml:"orderId"`
+	}
+	server := newTestServer(t, func(engine *gin.Engine) {
+		engine.POST("/order", NewGinHander(testProtoFn(nil), func(in order) (out order, err error) {
+			return in, nil
+		}))
+	})
+
+	client := NewClientProtocol("POST", server.URL+"/order?orderId=1")
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeXml)
+	var out order
+	err := client.Do(order{OrderId: "12"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.OrderId != "12" {
+		t.Fatalf("unexpected %+v", out)
+	}
+	if got := client.Response().HttpCode; got != 200 {
+		t.Fatalf("unexpected http code %d", got)
+	}
+}
+
+func TestBrowserAcceptNegotiation(t *testing.T) {
+	engine := newTestEngine()
+	engine.GET("/order", NewGinHander(envelopeProtoFn(RouteEnvelope_CodeMessage), func(in map[string]any) (out map[string]any, err error) {
+		return map[string]any{"a": 1}, nil
+	}))
+	req := httptest.NewRequest("GET", "/order", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if ct := w.Header().Get("Content-Type"); ct != ContentTypeJson || w.Body.String() != `{"code":"0","message":"success","data":{"a":1}}` {
+		t.Fatalf("content type:%s,body:%s", ct, w.Body.String())
+	}
+}
+
+func TestReadInputError(t *testing.T) {
+	type query struct {
+		PageSize int `json:"pageSize"`
+	}
+	engine := newTestEngine()
+	handler := NewGinHander(envelopeProtoFn(RouteEnvelope_CodeMessage), func(in query) (out query, err error) {
+		return in, nil
+	})
+	engine.GET("/orders", handler)
+	engine.POST("/orders", handler)
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/orders?pageSize=abc", nil),
+		httptest.NewRequest("POST", "/orders", strings.NewReader(`{"pageSize":`)),
+	} {
+		req.Header.Set("Content-Type", ContentTypeJson)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		var envelope Response
+		_ = json.Unmarshal(w.Body.Bytes(), &envelope)
+		if w.Code != http.StatusBadRequest || envelope.Code != Business_Code_Invalid_Params { // 参数解析失败拒绝请求，不以零值进入业务
+			t.Fatalf("%s %s: status:%d,body:%s", req.Method, req.URL, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestFormEmbeddedStruct(t *testing.T) {
+	type query struct {
+		PageInput
+		OrderId string `json:"orderId"`
+	}
+	engine := newTestEngine()
+	handler := NewGinHander(testProtoFn(nil), func(in query) (out query, err error) {
+		return in, nil
+	})
+	engine.GET("/orders", handler)
+	engine.POST("/orders", handler)
+	form := strings.NewReader("pageSize=10&pageIndex=2&orderId=12")
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/orders?pageSize=10&pageIndex=2&orderId=12", nil),
+		httptest.NewRequest("POST", "/orders", form),
+	} {
+		if req.Method == "POST" {
+			req.Header.Set("Content-Type", ContentTypeForm)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		var out query
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		if w.Code != http.StatusOK || out.PageSize != 10 || out.PageIndex != 2 || out.OrderId != "12" { // 嵌入结构体字段与json一致展开
+			t.Fatalf("%s %s: status:%d,body:%s", req.Method, req.URL, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package apihttpprotocol
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrCurlSyntax = errors.New("invalid curl command")
+
+// splitShellWords 按 shell 规则拆分命令，支持单引号、双引号、反斜杠转义及续行
+func splitShellWords(command string) (words []string, err error) {
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+				continue
+			}
+			word.WriteRune(r)
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+				continue
+			}
+			if r == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+				}
+				continue
+			}
+			word.WriteRune(r)
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' && runes[i] != '\r' {
+					word.WriteRune(runes[i])
+					inWord = true
+				}
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.WithMessage(ErrCurlSyntax, "unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// curlFlagsWithoutValue 重放时忽略的无参数选项
+var curlFlagsWithoutValue = map[string]bool{
+	"--compressed": true, "-k": true, "--insecure": true, "-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-v": true, "--verbose": true, "-L": true, "--location": true, "-i": true, "--include": true, "-f": true, "--fail": true,
+}
+
+// curlFlagsWithValue 重放时忽略的带参数选项
+var curlFlagsWithValue = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true, "--retry": true, "-w": true, "--write-out": true,
+}
+
+type curlFormField struct {
+	name     string
+	value    string
+	filename string
+}
+
+// ParseCurlCommand 将 curl 命令(如 CurlCommand、ResponseError.CurlCommand 的输出)解析为 http.Request，
+// 支持 -X、-H、-d/--data/--data-raw/--data-binary、--data-urlencode、-F、-u、-G、-A、-b、-e、-I 及 --url，
+// -F 中 @file 会读取本地文件
+func ParseCurlCommand(command string) (req *http.Request, err error) {
+	words, err := splitShellWords(strings.TrimSpace(command))
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 || words[0] != "curl" {
+		return nil, errors.WithMessage(ErrCurlSyntax, "command must start with curl")
+	}
+	method, rawUrl, user := "", "", ""
+	header := http.Header{}
+	dataList := make([]string, 0)
+	forms := make([]curlFormField, 0)
+	get, head := false, false
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+		name, value, hasValue := word, "", false
+		if strings.HasPrefix(word, "--") {
+			name, value, hasValue = strings.Cut(word, "=")
+		} else if strings.HasPrefix(word, "-") && len(word) > 2 { // -XPOST
+			name, value, hasValue = word[:2], word[2:], true
+		}
+		nextValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(words) {
+				return "", errors.WithMessagef(ErrCurlSyntax, "option %s requires a value", name)
+			}
+			i++
+			return words[i], nil
+		}
+		if !strings.HasPrefix(word, "-") {
+			rawUrl = word
+			continue
+		}
+		if curlFlagsWithoutValue[name] {
+			continue
+		}
+		switch name {
+		case "-G", "--get":
+			get = true
+			continue
+		case "-I", "--head":
+			head = true
+			continue
+		}
+		v, err := nextValue()
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "-X", "--request":
+			method = strings.ToUpper(v)
+		case "-H", "--header":
+			key, val, ok := strings.Cut(v, ":")
+			if !ok {
+				return nil, errors.WithMessagef(ErrCurlSyntax, "invalid header %s", v)
+			}
+			header.Add(strings.TrimSpace(key), strings.TrimSpace(val))
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii":
+			dataList = append(dataList, v)
+		case "--data-urlencode":
+			key, val, ok := strings.Cut(v, "=")
+			if ok {
+				dataList = append(dataList, url.QueryEscape(key)+"="+url.QueryEscape(val))
+			} else {
+				dataList = append(dataList, url.QueryEscape(v))
+			}
+		case "-F", "--form":
+			key, val, _ := strings.Cut(v, "=")
+			field := curlFormField{name: key, value: val}
+			if strings.HasPrefix(val, "@") {
+				field.filename = strings.TrimPrefix(val, "@")
+			}
+			forms = append(forms, field)
+		case "-u", "--user":
+			user = v
+		case "-A", "--user-agent":
+			header.Set("User-Agent", v)
+		case "-b", "--cookie":
+			header.Add("Cookie", v)
+		case "-e", "--referer":
+			header.Set("Referer", v)
+		case "--url":
+			rawUrl = v
+		default:
+			if !curlFlagsWithValue[name] {
+				return nil, errors.WithMessagef(ErrCurlSyntax, "unsupported option %s", name)
+			}
+		}
+	}
+	if rawUrl == "" {
+		return nil, errors.WithMessage(ErrCurlSyntax, "url not found")
+	}
+	if !strings.Contains(rawUrl, "://") {
+		rawUrl = "http://" + rawUrl
+	}
+	var body []byte
+	switch {
+	case len(forms) > 0:
+		body, err = curlMultipartBody(forms, header)
+		if err != nil {
+			return nil, err
+		}
+	case len(dataList) > 0 && get:
+		separator := "?"
+		if strings.Contains(rawUrl, "?") {
+			separator = "&"
+		}
+		rawUrl += separator + strings.Join(dataList, "&")
+	case len(dataList) > 0:
+		body = []byte(strings.Join(dataList, "&"))
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", ContentTypeForm) // 与 curl 默认行为一致
+		}
+	}
+	if method == "" {
+		switch {
+		case head:
+			method = http.MethodHead
+		case body != nil:
+			method = http.MethodPost
+		default:
+			method = http.MethodGet
+		}
+	}
+	req, err = http.NewRequest(method, rawUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithMessage(err, "ParseCurlCommand")
+	}
+	if body == nil {
+		req.Body, req.GetBody, req.ContentLength = http.NoBody, nil, 0
+	}
+	req.Header = header
+	if user != "" {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user)))
+	}
+	return req, nil
+}
+
+func curlMultipartBody(forms []curlFormField, header http.Header) (body []byte, err error) {
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+	for _, field := range forms {
+		if field.filename == "" {
+			err = writer.WriteField(field.name, field.value)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		filename, _, _ := strings.Cut(field.filename, ";") // 忽略 ;type= 等属性
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "read form file %s", filename)
+		}
+		part, err := writer.CreateFormFile(field.name, filepath.Base(filename))
+		if err != nil {
+			return nil, err
+		}
+		_, err = part.Write(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	header.Set("Content-Type", writer.FormDataContentType())
+	return buf.Bytes(), nil
+}
+
+// NewClientProtocolFromCurl 根据 curl 命令创建客户端，通过 Replay 重新执行，请求经过与普通调用相同的中间件链
+func NewClientProtocolFromCurl(command string) (client *ClientProtocol, err error) {
+	req, err := ParseCurlCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	client = NewClientProtocol(req.Method, req.URL.String())
+	err = client.Request().SetHttpRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Replay 使用 SetHttpRequest 填充的原始请求体重新发起请求
+func (c *ClientProtocol) Replay(dst any) (err error) {
+	var body any
+	if raw := c.request.GetRaw(); len(raw) > 0 {
+		body = raw
+	}
+	return c.Do(body, dst)
+}
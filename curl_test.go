@@ -0,0 +1,85 @@
+package apihttpprotocol
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseCurlCommand(t *testing.T) {
+	req, err := ParseCurlCommand(`curl -G 'http://api.local/search' \
+  --data-urlencode 'q=hello world' -d "page=2" -u admin:secret --compressed -H 'X-Trace: a:b'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "GET" || req.URL.Query().Get("q") != "hello world" || req.URL.Query().Get("page") != "2" {
+		t.Fatalf("unexpected request %s %s", req.Method, req.URL)
+	}
+	if user, password, ok := req.BasicAuth(); !ok || user != "admin" || password != "secret" || req.Header.Get("X-Trace") != "a:b" {
+		t.Fatalf("unexpected header %v", req.Header)
+	}
+
+	req, err = ParseCurlCommand(`curl -F name=book -F "note=a \"b\"" http://api.local/upload`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = req.ParseMultipartForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "POST" || req.FormValue("name") != "book" || req.FormValue("note") != `a "b"` {
+		t.Fatalf("unexpected form %v", req.MultipartForm)
+	}
+
+	req, _ = ParseCurlCommand(`curl -d 'a=1' http://api.local/form`)
+	body, _ := io.ReadAll(req.Body)
+	if req.Method != "POST" || req.Header.Get("Content-Type") != ContentTypeForm || string(body) != "a=1" {
+		t.Fatalf("unexpected data request %s %v %s", req.Method, req.Header, body)
+	}
+
+	if _, err = ParseCurlCommand(`curl --unknown-flag http://api.local`); err == nil {
+		t.Fatal("unsupported option should fail")
+	}
+}
+
+func TestReplayCurlCommand(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	protoFn := func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		p.SetLog(LogIgnore{})
+		return p
+	}
+	engine.POST("/order", NewGinHander(protoFn, func(in map[string]any) (out map[string]any, err error) {
+		return in, nil
+	}))
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	client := NewClientProtocol("POST", server.URL+"/order")
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeJson)
+	err := client.Do(map[string]any{"orderId": "it's"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := client.Request().CurlCommand()
+
+	replay, err := NewClientProtocolFromCurl(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay.SetLog(LogIgnore{})
+	replay.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+	var out map[string]any
+	err = replay.Replay(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["orderId"] != "it's" {
+		t.Fatalf("unexpected out %v,command:%s", out, command)
+	}
+}
@@ -0,0 +1,386 @@
+package apihttpprotocol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Scheme_Service 服务发现地址前缀，如 svc://order-service/api/order/list
+const Scheme_Service = "svc"
+
+var ErrNoEndpoint = errors.New("no available endpoint")
+
+// Resolver 将服务名解析为 host:port 列表
+type Resolver interface {
+	Resolve(ctx context.Context, service string) (endpoints []string, err error)
+}
+
+// StaticResolver 静态地址列表
+type StaticResolver map[string][]string
+
+func (r StaticResolver) Resolve(ctx context.Context, service string) (endpoints []string, err error) {
+	endpoints, ok := r[service]
+	if !ok || len(endpoints) == 0 {
+		return nil, errors.WithMessagef(ErrNoEndpoint, "service:%s", service)
+	}
+	return endpoints, nil
+}
+
+// DNSSRVResolver 通过 DNS SRV 记录解析，Service、Proto 为空时直接查询服务名(如 _http._tcp.order-service.default.svc)，地址选取见 srvEndpoints
+type DNSSRVResolver struct {
+	Service string
+	Proto   string
+	TTL     time.Duration // 解析结果缓存时长，默认10秒
+	mutex   sync.Mutex
+	cache   map[string]resolvedEndpoints
+}
+
+type resolvedEndpoints struct {
+	endpoints []string
+	expiresAt time.Time
+}
+
+func (r *DNSSRVResolver) Resolve(ctx context.Context, service string) (endpoints []string, err error) {
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	r.mutex.Lock()
+	cached, ok := r.cache[service]
+	r.mutex.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.endpoints, nil
+	}
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, r.Service, r.Proto, service)
+	if err != nil {
+		if ok { // 解析失败时沿用过期结果
+			return cached.endpoints, nil
+		}
+		return nil, errors.WithMessagef(err, "lookup srv service:%s", service)
+	}
+	endpoints = srvEndpoints(records)
+	if len(endpoints) == 0 {
+		return nil, errors.WithMessagef(ErrNoEndpoint, "service:%s", service)
+	}
+	r.mutex.Lock()
+	if r.cache == nil {
+		r.cache = map[string]resolvedEndpoints{}
+	}
+	r.cache[service] = resolvedEndpoints{endpoints: endpoints, expiresAt: time.Now().Add(ttl)}
+	r.mutex.Unlock()
+	return endpoints, nil
+}
+
+// maxSRVWeightSlots 权重展开后的最大地址数
+const maxSRVWeightSlots = 100
+
+// srvEndpoints 按 RFC 2782 只使用优先级(Priority)最小的一组记录，组内按权重(Weight)展开为平滑加权序列，轮询等均衡策略据此按权重分配；
+// 组内有非0权重时权重为0的记录不参与分配
+func srvEndpoints(records []*net.SRV) (endpoints []string) {
+	group := make([]*net.SRV, 0, len(records))
+	for _, record := range records {
+		if len(group) == 0 || record.Priority < group[0].Priority {
+			group = append(group[:0], record)
+			continue
+		}
+		if record.Priority == group[0].Priority {
+			group = append(group, record)
+		}
+	}
+	weights := make([]int, len(group))
+	total := 0
+	for i, record := range group {
+		weights[i] = int(record.Weight)
+		total += weights[i]
+	}
+	if total == 0 { // 权重都为0时均分
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = len(weights)
+	}
+	divisor := 0
+	for _, weight := range weights {
+		divisor = gcd(divisor, weight)
+	}
+	scaled := 0
+	for i, weight := range weights {
+		weight /= divisor
+		if total/divisor > maxSRVWeightSlots && weight > 0 {
+			weight = max(weight*maxSRVWeightSlots/(total/divisor), 1)
+		}
+		weights[i] = weight
+		scaled += weight
+	}
+	current := make([]int, len(group))
+	for slot := 0; slot < scaled; slot++ { // 平滑加权轮询，避免同一地址连续出现
+		best := -1
+		for i, weight := range weights {
+			current[i] += weight
+			if best < 0 || current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= scaled
+		endpoints = append(endpoints, net.JoinHostPort(strings.TrimSuffix(group[best].Target, "."), strconv.Itoa(int(group[best].Port))))
+	}
+	return endpoints
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// FileResolver 从 json/yaml 文件读取地址列表(格式 {"order-service":["127.0.0.1:8080"]})，文件修改后自动重新加载，便于本地测试
+type FileResolver struct {
+	Filename  string
+	mutex     sync.Mutex
+	modTime   time.Time
+	checkedAt time.Time
+	resolver  StaticResolver
+}
+
+func NewFileResolver(filename string) *FileResolver {
+	return &FileResolver{Filename: filename}
+}
+
+// load 最多每秒检查一次文件修改时间
+func (r *FileResolver) load() (resolver StaticResolver, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	if r.resolver != nil && now.Sub(r.checkedAt) < time.Second {
+		return r.resolver, nil
+	}
+	r.checkedAt = now
+	info, err := os.Stat(r.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if r.resolver != nil && info.ModTime().Equal(r.modTime) {
+		return r.resolver, nil
+	}
+	b, err := os.ReadFile(r.Filename)
+	if err != nil {
+		return nil, err
+	}
+	resolver = StaticResolver{}
+	if strings.HasSuffix(r.Filename, ".json") {
+		err = json.Unmarshal(b, &resolver)
+	} else {
+		err = yaml.Unmarshal(b, &resolver)
+	}
+	if err != nil {
+		return nil, errors.WithMessagef(err, "parse resolver file:%s", r.Filename)
+	}
+	r.resolver, r.modTime = resolver, info.ModTime()
+	return resolver, nil
+}
+
+func (r *FileResolver) Resolve(ctx context.Context, service string) (endpoints []string, err error) {
+	resolver, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	return resolver.Resolve(ctx, service)
+}
+
+// Balancer 负载均衡，Pick 返回的 done 在请求结束后调用
+type Balancer interface {
+	Pick(service string, endpoints []string, req *http.Request) (endpoint string, done func())
+}
+
+// RoundRobinBalancer 轮询
+type RoundRobinBalancer struct {
+	counters sync.Map // service => *atomic.Uint64
+}
+
+func (b *RoundRobinBalancer) Pick(service string, endpoints []string, req *http.Request) (endpoint string, done func()) {
+	v, _ := b.counters.LoadOrStore(service, new(atomic.Uint64))
+	index := v.(*atomic.Uint64).Add(1) - 1
+	return endpoints[index%uint64(len(endpoints))], func() {}
+}
+
+// LeastInflightBalancer 选择进行中请求数最少的地址
+type LeastInflightBalancer struct {
+	mutex    sync.Mutex
+	inflight map[string]int
+}
+
+func (b *LeastInflightBalancer) Pick(service string, endpoints []string, req *http.Request) (endpoint string, done func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.inflight == nil {
+		b.inflight = map[string]int{}
+	}
+	endpoint = endpoints[0]
+	for _, item := range endpoints[1:] {
+		if b.inflight[item] < b.inflight[endpoint] {
+			endpoint = item
+		}
+	}
+	b.inflight[endpoint]++
+	return endpoint, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		b.inflight[endpoint]--
+		if b.inflight[endpoint] <= 0 {
+			delete(b.inflight, endpoint)
+		}
+	}
+}
+
+// ConsistentHashBalancer 一致性哈希(rendezvous hashing)，相同key固定落到同一地址，地址增减时只影响部分key
+type ConsistentHashBalancer struct {
+	KeyFn func(req *http.Request) string // 默认使用请求路径
+}
+
+func (b ConsistentHashBalancer) Pick(service string, endpoints []string, req *http.Request) (endpoint string, done func()) {
+	key := req.URL.Path
+	if b.KeyFn != nil {
+		key = b.KeyFn(req)
+	}
+	var max uint64
+	for _, item := range endpoints {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s|%s", item, key)
+		if score := h.Sum64(); endpoint == "" || score > max {
+			endpoint, max = item, score
+		}
+	}
+	return endpoint, func() {}
+}
+
+type endpointHealth struct {
+	failures     int
+	ejectedUntil time.Time
+}
+
+type DiscoveryConfig struct {
+	Resolver      Resolver
+	Balancer      Balancer      // 默认轮询
+	Scheme        string        // 解析后使用的协议，默认 http
+	MaxFailures   int           // 连续失败(网络错误、5xx)次数达到该值时摘除，默认3
+	EjectDuration time.Duration // 摘除时长，默认30秒
+}
+
+// ServiceDiscovery 服务发现，保存被动健康检查状态，需全局共享(不要在每次请求时创建)
+type ServiceDiscovery struct {
+	config DiscoveryConfig
+	mutex  sync.Mutex
+	health map[string]*endpointHealth
+}
+
+func NewServiceDiscovery(config DiscoveryConfig) *ServiceDiscovery {
+	if config.Balancer == nil {
+		config.Balancer = &RoundRobinBalancer{}
+	}
+	if config.Scheme == "" {
+		config.Scheme = "http"
+	}
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = 3
+	}
+	if config.EjectDuration <= 0 {
+		config.EjectDuration = 30 * time.Second
+	}
+	return &ServiceDiscovery{
+		config: config,
+		health: map[string]*endpointHealth{},
+	}
+}
+
+// healthy 过滤被摘除的地址，全部被摘除时返回全部地址
+func (d *ServiceDiscovery) healthy(endpoints []string) []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := time.Now()
+	available := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		health, ok := d.health[endpoint]
+		if ok && now.Before(health.ejectedUntil) {
+			continue
+		}
+		available = append(available, endpoint)
+	}
+	if len(available) == 0 {
+		return endpoints
+	}
+	return available
+}
+
+// report 记录请求结果
+func (d *ServiceDiscovery) report(endpoint string, failed bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !failed {
+		delete(d.health, endpoint)
+		return
+	}
+	health, ok := d.health[endpoint]
+	if !ok {
+		health = &endpointHealth{}
+		d.health[endpoint] = health
+	}
+	health.failures++
+	if health.failures >= d.config.MaxFailures {
+		health.failures = 0
+		health.ejectedUntil = time.Now().Add(d.config.EjectDuration)
+	}
+}
+
+// Ejected 地址是否被摘除
+func (d *ServiceDiscovery) Ejected(endpoint string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	health, ok := d.health[endpoint]
+	return ok && time.Now().Before(health.ejectedUntil)
+}
+
+// ResponseMiddleDiscovery 客户端服务发现，将 svc://service-name/path 解析为具体地址，选中的地址记录在 MetaData_Endpoint
+func ResponseMiddleDiscovery(discovery *ServiceDiscovery) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		message.WrapDo(func(next DoFunc) DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				if req.URL.Scheme != Scheme_Service {
+					return next(req)
+				}
+				service := req.URL.Host
+				endpoints, err := discovery.config.Resolver.Resolve(req.Context(), service)
+				if err != nil {
+					return nil, err
+				}
+				if len(endpoints) == 0 {
+					return nil, errors.WithMessagef(ErrNoEndpoint, "service:%s", service)
+				}
+				endpoint, done := discovery.config.Balancer.Pick(service, discovery.healthy(endpoints), req)
+				defer done()
+				outReq, err := endpointRequest(req.Context(), req, fmt.Sprintf("%s://%s", discovery.config.Scheme, endpoint))
+				if err != nil {
+					return nil, err
+				}
+				message.SetMetaData(MetaData_Endpoint, endpoint)
+				response, err := next(outReq)
+				discovery.report(endpoint, err != nil || response.StatusCode >= http.StatusInternalServerError)
+				return response, err
+			}
+		})
+		return message.Next()
+	}
+}
@@ -0,0 +1,85 @@
+package apihttpprotocol
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServiceDiscovery(t *testing.T) {
+	newServer := func(status int, name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ContentTypeJson)
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code":"0","message":"success","data":"` + name + `"}`))
+		}))
+	}
+	good := newServer(http.StatusOK, "good")
+	defer good.Close()
+	bad := newServer(http.StatusInternalServerError, "bad")
+	defer bad.Close()
+	goodHost, badHost := hostOf(good.URL), hostOf(bad.URL)
+
+	filename := filepath.Join(t.TempDir(), "services.yaml")
+	err := os.WriteFile(filename, []byte("order-service:\n  - "+badHost+"\n  - "+goodHost+"\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	discovery := NewServiceDiscovery(DiscoveryConfig{
+		Resolver:    NewFileResolver(filename),
+		MaxFailures: 1,
+	})
+	for i := 0; i < 4; i++ {
+		client := NewClientProtocol("GET", "svc://order-service/api/order")
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(ResponseMiddleDiscovery(discovery), ResponseMiddleCodeMessageForClient)
+		var out string
+		_ = client.Do(nil, &out)
+	}
+	if !discovery.Ejected(badHost) || discovery.Ejected(goodHost) {
+		t.Fatal("failing endpoint should be ejected")
+	}
+
+	picked := map[string]bool{}
+	balancer := ConsistentHashBalancer{}
+	req := httptest.NewRequest("GET", "/api/order?id=1", nil)
+	for i := 0; i < 3; i++ {
+		endpoint, _ := balancer.Pick("order-service", []string{"a:80", "b:80", "c:80"}, req)
+		picked[endpoint] = true
+	}
+	if len(picked) != 1 {
+		t.Fatalf("consistent hash should pick same endpoint,got %v", picked)
+	}
+}
+
+func hostOf(rawUrl string) string {
+	u, _ := url.Parse(rawUrl)
+	return u.Host
+}
+
+func TestSRVEndpoints(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "backup.", Port: 80, Priority: 20, Weight: 100},
+		{Target: "a.", Port: 80, Priority: 10, Weight: 30},
+		{Target: "b.", Port: 80, Priority: 10, Weight: 10},
+		{Target: "c.", Port: 80, Priority: 10, Weight: 0},
+	}
+	endpoints := srvEndpoints(records)
+	if strings.Join(endpoints, ",") != "a:80,a:80,b:80,a:80" { // 只用最小优先级组，按权重3:1平滑分配，权重0不参与
+		t.Fatalf("endpoints:%v", endpoints)
+	}
+	endpoints = srvEndpoints([]*net.SRV{{Target: "a.", Port: 80, Weight: 0}, {Target: "b.", Port: 80, Weight: 0}})
+	if strings.Join(endpoints, ",") != "a:80,b:80" {
+		t.Fatalf("zero weights:%v", endpoints)
+	}
+	endpoints = srvEndpoints([]*net.SRV{{Target: "a.", Port: 80, Weight: 65535}, {Target: "b.", Port: 80, Weight: 1}})
+	if len(endpoints) > maxSRVWeightSlots || !slices.Contains(endpoints, "b:80") {
+		t.Fatalf("large weights:%d", len(endpoints))
+	}
+}
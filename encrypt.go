@@ -0,0 +1,232 @@
+package apihttpprotocol
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	Header_CallerServiceId = "X-Caller-Service-Id"
+	MetaData_EncryptKeyId  = "encrypt_key_id"
+)
+
+var (
+	Business_Code_Decrypt_Fail = "400001"
+)
+
+// EncryptConfig 业务数据加密配置，客户端、服务端共用
+type EncryptConfig struct {
+	KeyId     string                                     // 客户端使用，写入请求头 Header_CallerServiceId 及 _callerServiceId，服务端据此查找密钥
+	KeyLookup func(keyId string) (key []byte, err error) // 根据密钥ID(调用方服务ID)获取AES密钥(16/24/32字节)
+}
+
+func (c EncryptConfig) lookup(keyId string) (key []byte, err error) {
+	if c.KeyLookup == nil {
+		return nil, errors.New("encrypt key lookup not configured")
+	}
+	return c.KeyLookup(keyId)
+}
+
+// EncryptedPayload 加密后的业务数据
+type EncryptedPayload struct {
+	CallerServiceId string `json:"_callerServiceId,omitempty"`
+	Ciphertext      string `json:"ciphertext"` // base64(nonce+密文)
+}
+
+// AesGcmEncrypt AES-GCM 加密，返回 nonce+密文
+func AesGcmEncrypt(key []byte, plaintext []byte) (ciphertext []byte, err error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func AesGcmDecrypt(key []byte, ciphertext []byte) (plaintext []byte, err error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func newGcm(key []byte) (gcm cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptValue 序列化时加密原始数据
+type encryptValue struct {
+	plain any
+	keyId string
+	key   []byte
+}
+
+func (v encryptValue) MarshalJSON() ([]byte, error) {
+	plaintext, err := DefaultCodec().Marshal(v.plain)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := AesGcmEncrypt(v.key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	payload := EncryptedPayload{
+		CallerServiceId: v.keyId,
+		Ciphertext:      base64.StdEncoding.EncodeToString(ciphertext),
+	}
+	return json.Marshal(payload)
+}
+
+// decryptTarget 反序列化时解密并解码到原始目标
+type decryptTarget struct {
+	dst     any
+	keyId   string                             // 为空时使用数据中的 _callerServiceId
+	keyFn   func(keyId string) ([]byte, error) // 查找密钥
+	onKeyId func(keyId string)                 // 记录实际使用的密钥ID
+}
+
+// goStructRefWrapper 包装了原始目标的 GoStructRef
+type goStructRefWrapper interface {
+	unwrapGoStructRef() any
+}
+
+func (t *decryptTarget) unwrapGoStructRef() any {
+	return t.dst
+}
+
+func (t *decryptTarget) UnmarshalJSON(b []byte) (err error) {
+	if string(b) == "null" {
+		return nil
+	}
+	err = t.decrypt(b)
+	if err != nil {
+		return NewCodeError(Business_Code_Decrypt_Fail, err.Error()).WithHttpStatus(http.StatusBadRequest)
+	}
+	return nil
+}
+
+func (t *decryptTarget) decrypt(b []byte) (err error) {
+	var payload EncryptedPayload
+	err = json.Unmarshal(b, &payload)
+	if err != nil {
+		return errors.WithMessage(err, "invalid encrypted payload")
+	}
+	keyId := t.keyId
+	if keyId == "" {
+		keyId = payload.CallerServiceId
+	}
+	key, err := t.keyFn(keyId)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(payload.Ciphertext)
+	if err != nil {
+		return errors.WithMessage(err, "invalid ciphertext encoding")
+	}
+	plaintext, err := AesGcmDecrypt(key, ciphertext)
+	if err != nil {
+		return errors.WithMessage(err, "decrypt")
+	}
+	if t.onKeyId != nil {
+		t.onKeyId(keyId)
+	}
+	if t.dst == nil {
+		return nil
+	}
+	return DefaultCodec().Unmarshal(plaintext, t.dst)
+}
+
+// RequestMiddleEncrypt 客户端请求加密，请求体整体加密
+func RequestMiddleEncrypt(config EncryptConfig) HandlerFunc[RequestMessage] {
+	return func(message *RequestMessage) (err error) {
+		if message.GoStructRef != nil {
+			key, err := config.lookup(config.KeyId)
+			if err != nil {
+				return err
+			}
+			message.GoStructRef = encryptValue{plain: message.GoStructRef, keyId: config.KeyId, key: key}
+		}
+		message.Headers.Set(Header_CallerServiceId, config.KeyId)
+		return message.Next()
+	}
+}
+
+// ResponseMiddleDecrypt 客户端响应解密，放在 ResponseMiddleCodeMessageForClient 之前时只解密信封中的业务数据
+func ResponseMiddleDecrypt(config EncryptConfig) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		message.GoStructRef = &decryptTarget{
+			dst:   message.GoStructRef,
+			keyId: config.KeyId,
+			keyFn: config.lookup,
+		}
+		return message.Next()
+	}
+}
+
+// callerServiceId 调用方服务ID，优先取请求头 Header_CallerServiceId，其次取二层协议请求体 _head._callerServiceId
+func callerServiceId(message *RequestMessage) (id string) {
+	id = message.GetHeader(Header_CallerServiceId)
+	if id == "" {
+		id = CredentialFromBody("_head._callerServiceId")(message)
+	}
+	return id
+}
+
+// RequestMiddleDecrypt 服务端请求解密，密钥ID依次取请求头 Header_CallerServiceId、二层协议 _head._callerServiceId、加密数据中的 _callerServiceId
+func RequestMiddleDecrypt(config EncryptConfig) HandlerFunc[RequestMessage] {
+	return func(message *RequestMessage) (err error) {
+		keyId := callerServiceId(message)
+		if keyId != "" {
+			message.SetMetaData(MetaData_EncryptKeyId, keyId)
+		}
+		message.GoStructRef = &decryptTarget{
+			dst:   message.GoStructRef,
+			keyId: keyId,
+			keyFn: config.lookup,
+			onKeyId: func(keyId string) {
+				message.SetMetaData(MetaData_EncryptKeyId, keyId)
+			},
+		}
+		return message.Next()
+	}
+}
+
+// ResponseMiddleEncrypt 服务端响应加密，使用请求解密时的密钥，放在 ResponseMiddleCodeMessageForServer 之前时只加密信封中的业务数据
+func ResponseMiddleEncrypt(config EncryptConfig) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		if message.GoStructRef == nil {
+			return message.Next()
+		}
+		keyId := config.KeyId
+		if requestMessage, ok := message.GetRequestMessage(); ok {
+			if v, exists := requestMessage.MetaData.Get(MetaData_EncryptKeyId); exists {
+				keyId, _ = v.(string)
+			}
+		}
+		key, err := config.lookup(keyId)
+		if err != nil {
+			return err
+		}
+		message.GoStructRef = encryptValue{plain: message.GoStructRef, keyId: keyId, key: key}
+		return message.Next()
+	}
+}
@@ -0,0 +1,120 @@
+package apihttpprotocol
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type encryptOrder struct {
+	OrderId string `json:"orderId"`
+	Source  string `json:"source,omitempty"`
+}
+
+var encryptTestKeys = map[string][]byte{
+	"110001": []byte("0123456789abcdef0123456789abcdef"),
+}
+
+func encryptTestKeyLookup(keyId string) (key []byte, err error) {
+	key, ok := encryptTestKeys[keyId]
+	if !ok {
+		return nil, errors.Errorf("unknown caller:%s", keyId)
+	}
+	return key, nil
+}
+
+func encryptTestWrongKey(keyId string) (key []byte, err error) {
+	return []byte("fedcba9876543210fedcba9876543210"), nil
+}
+
+// newEncryptTestServer 解密请求、加密响应并原样返回订单的测试服务
+func newEncryptTestServer(t *testing.T) *httptest.Server {
+	config := EncryptConfig{KeyLookup: encryptTestKeyLookup}
+	protoFn := testProtoFn(func(p *ServerProtocol) {
+		p.Request().AddMiddleware(RequestMiddleDecrypt(config))
+		p.Response().AddMiddleware(ResponseMiddleEncrypt(config), ResponseMiddleCodeMessageForServer)
+	})
+	return newTestServer(t, func(engine *gin.Engine) {
+		engine.POST("/order", NewGinHander(protoFn, func(in encryptOrder) (out encryptOrder, err error) {
+			return in, nil
+		}))
+	})
+}
+
+// newEncryptTestClient 请求使用 requestKey 加密，响应使用 responseKey 解密
+func newEncryptTestClient(url string, requestKey func(keyId string) ([]byte, error), responseKey func(keyId string) ([]byte, error)) *ClientProtocol {
+	client := NewClientProtocol("POST", url)
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeJson)
+	client.Request().AddMiddleware(RequestMiddleEncrypt(EncryptConfig{KeyId: "110001", KeyLookup: requestKey}))
+	client.Response().AddMiddleware(ResponseMiddleDecrypt(EncryptConfig{KeyId: "110001", KeyLookup: responseKey}), ResponseMiddleCodeMessageForClient)
+	return client
+}
+
+func TestEncrypt(t *testing.T) {
+	server := newEncryptTestServer(t)
+	client := newEncryptTestClient(server.URL+"/order", encryptTestKeyLookup, encryptTestKeyLookup)
+	var out encryptOrder
+	err := client.Do(encryptOrder{OrderId: "12"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.OrderId != "12" {
+		t.Fatalf("unexpected %+v", out)
+	}
+	if raw := string(client.Response().GetRaw()); strings.Contains(raw, "orderId") || !strings.Contains(raw, `"code":"0"`) {
+		t.Fatalf("business data should be encrypted inside envelope:%s", raw)
+	}
+}
+
+func TestEncryptQueryParams(t *testing.T) {
+	server := newEncryptTestServer(t)
+	client := newEncryptTestClient(server.URL+"/order?source=app", encryptTestKeyLookup, encryptTestKeyLookup) // url 参数与加密请求体合并
+	var out encryptOrder
+	err := client.Do(encryptOrder{OrderId: "12"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.OrderId != "12" || out.Source != "app" {
+		t.Fatalf("query param lost:%+v", out)
+	}
+}
+
+func TestEncryptTwoLayerCaller(t *testing.T) {
+	server := newEncryptTestServer(t)
+	ciphertext, err := AesGcmEncrypt(encryptTestKeys["110001"], []byte(`{"orderId":"13"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := fmt.Sprintf(`{"_head":{"_callerServiceId":"110001"},"ciphertext":%q}`, base64.StdEncoding.EncodeToString(ciphertext))
+	rsp, err := http.Post(server.URL+"/order", ContentTypeJson, strings.NewReader(body)) // 二层协议 _head 中的调用方
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("two layer caller service id,status:%d", rsp.StatusCode)
+	}
+}
+
+func TestEncryptWrongKey(t *testing.T) {
+	server := newEncryptTestServer(t)
+	var out encryptOrder
+	client := newEncryptTestClient(server.URL+"/order", encryptTestWrongKey, encryptTestWrongKey)
+	err := client.Do(encryptOrder{OrderId: "12"}, &out)
+	if err == nil || !strings.Contains(err.Error(), Business_Code_Decrypt_Fail) {
+		t.Fatalf("expected decrypt fail code,got %v", err)
+	}
+
+	client = newEncryptTestClient(server.URL+"/order", encryptTestKeyLookup, encryptTestWrongKey) // 服务端正常响应，客户端解密失败
+	err = client.Do(encryptOrder{OrderId: "12"}, &out)
+	if getBusinessCode(err) != Business_Code_Decrypt_Fail || client.Response().HttpCode != http.StatusOK {
+		t.Fatalf("expected client decrypt fail,httpCode:%d,got %v", client.Response().HttpCode, err)
+	}
+}
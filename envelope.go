@@ -0,0 +1,234 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OneLayerResponseOf 标准一层协议响应体，Data 为具体类型
+type OneLayerResponseOf[T any] struct {
+	ErrCode string `json:"_errCode"`
+	ErrStr  string `json:"_errStr"`
+	Ret     string `json:"_ret"`
+	Data    T      `json:"_data"`
+}
+
+type OneLayerResponse = OneLayerResponseOf[any]
+
+func (rsp *OneLayerResponseOf[T]) Validate() (err error) {
+	if rsp.ErrCode != Business_Code_Success {
+		if rsp.ErrStr == "" {
+			rsp.ErrStr = fmt.Sprintf("%v", rsp.Data)
+		}
+		err = errors.Errorf("response err code:%s,message:%s", rsp.ErrCode, rsp.ErrStr)
+		return err
+	}
+	return nil
+}
+
+func (rsp OneLayerResponseOf[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ErrCode string `json:"_errCode"`
+		ErrStr  string `json:"_errStr"`
+		Ret     string `json:"_ret"`
+		Data    any    `json:"_data"`
+	}{
+		ErrCode: rsp.ErrCode,
+		ErrStr:  rsp.ErrStr,
+		Ret:     rsp.Ret,
+		Data:    WrapProtoJSON(rsp.Data),
+	})
+}
+
+func (rsp *OneLayerResponseOf[T]) UnmarshalJSON(b []byte) (err error) {
+	raw := struct {
+		ErrCode string          `json:"_errCode"`
+		ErrStr  string          `json:"_errStr"`
+		Ret     string          `json:"_ret"`
+		Data    json.RawMessage `json:"_data"`
+	}{}
+	err = json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+	rsp.ErrCode, rsp.ErrStr, rsp.Ret = raw.ErrCode, raw.ErrStr, raw.Ret
+	return unmarshalEnvelopeData(raw.Data, &rsp.Data)
+}
+
+// TwoLayerHead 标准二层协议 _head
+type TwoLayerHead struct {
+	Version         string `json:"_version"`
+	MsgType         string `json:"_msgType"`
+	Timestamps      string `json:"_timestamps"`
+	InvokeId        string `json:"_invokeId"`
+	CallerServiceId string `json:"_callerServiceId"`
+	GroupNo         string `json:"_groupNo"`
+	Interface       string `json:"_interface"`
+	Remark          string `json:"_remark"`
+}
+
+// TwoLayerRequest 标准二层协议请求体
+type TwoLayerRequest struct {
+	Head  TwoLayerHead `json:"_head"`
+	Param any          `json:"_param"`
+}
+
+// unwrapGoStructRef 服务端 url、表单参数解码到业务参数
+func (r *TwoLayerRequest) unwrapGoStructRef() any {
+	return r.Param
+}
+
+const (
+	MetaData_TwoLayerHead = "two_layer_head" // 服务端收到的二层协议请求头，响应时沿用
+)
+
+// TwoLayerResponseOf 标准二层协议响应体，Data.Data 为具体类型
+type TwoLayerResponseOf[T any] struct {
+	Head TwoLayerHead          `json:"_head"`
+	Data OneLayerResponseOf[T] `json:"_data"`
+}
+
+type TwoLayerResponse = TwoLayerResponseOf[any]
+
+// ResponseMiddleOneLayerForClient 客户端解析标准一层协议响应，Do 的响应参数可为任意类型，委托 ResponseMiddleOneLayerForClientOf 处理
+func ResponseMiddleOneLayerForClient(message *ResponseMessage) (err error) {
+	message.GoStructRef = anyResponseTarget(message)
+	return ResponseMiddleOneLayerForClientOf[any](message)
+}
+
+// RequestMiddleTwoLayerForClient 客户端按标准二层协议包装请求，head 中未设置的版本、时间戳、调用ID、调用方服务ID自动填充
+func RequestMiddleTwoLayerForClient(head TwoLayerHead) HandlerFunc[RequestMessage] {
+	return func(message *RequestMessage) (err error) {
+		h := head
+		h.MsgType = "request"
+		if h.Version == "" {
+			h.Version = "0.01"
+		}
+		if h.Timestamps == "" {
+			h.Timestamps = strconv.FormatInt(time.Now().Unix(), 10)
+		}
+		if h.InvokeId == "" {
+			h.InvokeId = message.GetRequestId()
+		}
+		if h.CallerServiceId == "" {
+			h.CallerServiceId = message.GetHeader(Header_CallerServiceId) // 与加密中间件使用的调用方一致
+		}
+		message.GoStructRef = &TwoLayerRequest{
+			Head:  h,
+			Param: WrapProtoJSON(message.GoStructRef),
+		}
+		return message.Next()
+	}
+}
+
+// ResponseMiddleTwoLayerForClient 客户端解析标准二层协议响应，Do 的响应参数可为任意类型，委托 ResponseMiddleTwoLayerForClientOf 处理
+func ResponseMiddleTwoLayerForClient(message *ResponseMessage) (err error) {
+	message.GoStructRef = anyResponseTarget(message)
+	return ResponseMiddleTwoLayerForClientOf[any](message)
+}
+
+// ResponseMiddleOneLayerForClientOf 按 OneLayerResponseOf[T] 解码，Do 的响应参数需为 *T 或 nil
+func ResponseMiddleOneLayerForClientOf[T any](message *ResponseMessage) (err error) {
+	out, err := responseTargetOf[T](message)
+	if err != nil {
+		return err
+	}
+	response := &OneLayerResponseOf[T]{}
+	if out != nil {
+		response.Data = *out
+	}
+	message.GoStructRef = response
+	err = message.Next()
+	if err != nil {
+		return remoteErrorFromResponseError(message, err)
+	}
+	err = response.Validate()
+	if err != nil {
+		return newRemoteError(message, response.ErrCode, response.ErrStr, nil, response.Data)
+	}
+	if out != nil {
+		*out = response.Data
+	}
+	return nil
+}
+
+// ResponseMiddleTwoLayerForClientOf 按 TwoLayerResponseOf[T] 解码，Do 的响应参数需为 *T 或 nil
+func ResponseMiddleTwoLayerForClientOf[T any](message *ResponseMessage) (err error) {
+	out, err := responseTargetOf[T](message)
+	if err != nil {
+		return err
+	}
+	response := &TwoLayerResponseOf[T]{}
+	if out != nil {
+		response.Data.Data = *out
+	}
+	message.GoStructRef = response
+	err = message.Next()
+	if err != nil {
+		return remoteErrorFromResponseError(message, err)
+	}
+	err = response.Data.Validate()
+	if err != nil {
+		return newRemoteError(message, response.Data.ErrCode, response.Data.ErrStr, nil, response.Data.Data)
+	}
+	if out != nil {
+		*out = response.Data.Data
+	}
+	return nil
+}
+
+// ResponseMiddleOneLayerForServer 服务端按标准一层协议输出
+func ResponseMiddleOneLayerForServer(message *ResponseMessage) (err error) {
+	code := message.GetBusinessCode()
+	message.GoStructRef = &OneLayerResponse{
+		ErrCode: code,
+		ErrStr:  message.GetBusinessMessage(),
+		Ret:     code,
+		Data:    WrapProtoJSON(message.GoStructRef),
+	}
+	return message.Next()
+}
+
+// RequestMiddleTwoLayerForServer 服务端解析标准二层协议请求，_param 解码到业务参数，_head 记录在 MetaData_TwoLayerHead
+func RequestMiddleTwoLayerForServer(message *RequestMessage) (err error) {
+	request := &TwoLayerRequest{
+		Param: WrapProtoJSON(message.GoStructRef),
+	}
+	message.GoStructRef = request
+	err = message.Next()
+	if err != nil {
+		return err
+	}
+	message.SetMetaData(MetaData_TwoLayerHead, request.Head)
+	return nil
+}
+
+// ResponseMiddleTwoLayerForServer 服务端按标准二层协议输出，_head 沿用请求头并改为 response
+func ResponseMiddleTwoLayerForServer(message *ResponseMessage) (err error) {
+	head := TwoLayerHead{}
+	if requestMessage, ok := message.GetRequestMessage(); ok {
+		if v, exists := requestMessage.MetaData.Get(MetaData_TwoLayerHead); exists {
+			head, _ = v.(TwoLayerHead)
+		}
+	}
+	head.MsgType = "response"
+	head.Timestamps = strconv.FormatInt(time.Now().Unix(), 10)
+	if head.Version == "" {
+		head.Version = "0.01"
+	}
+	code := message.GetBusinessCode()
+	message.GoStructRef = &TwoLayerResponse{
+		Head: head,
+		Data: OneLayerResponse{
+			ErrCode: code,
+			ErrStr:  message.GetBusinessMessage(),
+			Ret:     code,
+			Data:    WrapProtoJSON(message.GoStructRef),
+		},
+	}
+	return message.Next()
+}
@@ -0,0 +1,163 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+type envelopeOrder struct {
+	Id     string   `json:"id"`
+	Amount float64  `json:"amount"`
+	Tags   []string `json:"tags"`
+}
+
+func envelopeBodies(data string) map[string]string {
+	return map[string]string{
+		"codeMessage": `{"code":"0","message":"success","data":` + data + `}`,
+		"oneLayer":    `{"_errCode":"0","_errStr":"","_ret":"0","_data":` + data + `}`,
+		"twoLayer":    `{"_head":{"_msgType":"response"},"_data":{"_errCode":"0","_errStr":"","_ret":"0","_data":` + data + `}}`,
+	}
+}
+
+func envelopeMiddlewareOf[T any](protocol string) HandlerFuncResponseMessage {
+	switch protocol {
+	case "oneLayer":
+		return ResponseMiddleOneLayerForClientOf[T]
+	case "twoLayer":
+		return ResponseMiddleTwoLayerForClientOf[T]
+	default:
+		return ResponseMiddleCodeMessageForClientOf[T]
+	}
+}
+
+func testEnvelopeOf[T any](t *testing.T, data string, expected T) {
+	t.Helper()
+	for protocol, body := range envelopeBodies(data) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ContentTypeJson)
+			w.Write([]byte(body))
+		}))
+		client := NewClientProtocol(http.MethodGet, server.URL)
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(envelopeMiddlewareOf[T](protocol))
+		var out T
+		err := client.Do(nil, &out)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s %T: %v", protocol, out, err)
+		}
+		if !reflect.DeepEqual(out, expected) {
+			t.Fatalf("%s %T: expected %#v,got %#v", protocol, out, expected, out)
+		}
+	}
+}
+
+func TestResponseOf(t *testing.T) {
+	testEnvelopeOf(t, `{"id":"1","amount":9.5,"tags":["a"]}`, envelopeOrder{Id: "1", Amount: 9.5, Tags: []string{"a"}})
+	testEnvelopeOf(t, `{"id":"1","amount":9.5,"tags":null}`, &envelopeOrder{Id: "1", Amount: 9.5})
+	testEnvelopeOf(t, `[{"id":"1"},{"id":"2"}]`, []envelopeOrder{{Id: "1"}, {Id: "2"}})
+	testEnvelopeOf(t, `{"a":1,"b":2}`, map[string]int{"a": 1, "b": 2})
+	testEnvelopeOf(t, `"ok"`, "ok")
+	testEnvelopeOf(t, `42`, 42)
+	testEnvelopeOf(t, `true`, true)
+	testEnvelopeOf(t, `null`, 0)
+}
+
+func TestResponseOfProto(t *testing.T) {
+	rsp := ResponseOf[*typepb.Field]{Code: Business_Code_Success, Data: &typepb.Field{Kind: typepb.Field_TYPE_STRING, TypeUrl: "order"}}
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := ResponseOf[*typepb.Field]{}
+	err = json.Unmarshal(b, &decoded)
+	if err != nil {
+		t.Fatal(err, string(b))
+	}
+	if decoded.Data.Kind != typepb.Field_TYPE_STRING || decoded.Data.TypeUrl != "order" {
+		t.Fatalf("decoded:%s", string(b))
+	}
+}
+
+func TestResponseOfFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"404100","message":"订单不存在","data":{"id":"1"}}`))
+	}))
+	defer server.Close()
+	client := NewClientProtocol(http.MethodGet, server.URL)
+	client.SetLog(LogIgnore{})
+	client.Response().AddMiddleware(ResponseMiddleCodeMessageForClientOf[envelopeOrder])
+	var out envelopeOrder
+	err := client.Do(nil, &out)
+	var remoteErr *RemoteError
+	if !errors.As(err, &remoteErr) || remoteErr.Code != "404100" || remoteErr.Data.(envelopeOrder).Id != "1" {
+		t.Fatalf("err:%v", err)
+	}
+
+	client = NewClientProtocol(http.MethodGet, server.URL)
+	client.SetLog(LogIgnore{})
+	client.Response().AddMiddleware(ResponseMiddleCodeMessageForClientOf[envelopeOrder])
+	var wrong []envelopeOrder
+	if err = client.Do(nil, &wrong); err == nil {
+		t.Fatal("expected type mismatch error")
+	}
+}
+
+var legacyEnvelopeMiddlewares = map[string]HandlerFuncResponseMessage{
+	"codeMessage": ResponseMiddleCodeMessageForClient,
+	"oneLayer":    ResponseMiddleOneLayerForClient,
+	"twoLayer":    ResponseMiddleTwoLayerForClient,
+}
+
+func TestEnvelopeResponseError(t *testing.T) {
+	bodies := map[string]string{
+		"codeMessage": `{"code":"404100","message":"订单不存在","data":null}`,
+		"oneLayer":    `{"_errCode":"404100","_errStr":"订单不存在","_ret":"404100","_data":null}`,
+		"twoLayer":    `{"_head":{"_msgType":"response"},"_data":{"_errCode":"404100","_errStr":"订单不存在","_ret":"404100","_data":null}}`,
+	}
+	for protocol, body := range bodies {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ContentTypeJson)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(body))
+		}))
+		for _, middleware := range []HandlerFuncResponseMessage{legacyEnvelopeMiddlewares[protocol], envelopeMiddlewareOf[envelopeOrder](protocol)} {
+			client := NewClientProtocol(http.MethodGet, server.URL)
+			client.SetLog(LogIgnore{})
+			client.Response().AddMiddleware(middleware)
+			var out envelopeOrder
+			err := client.Do(nil, &out)
+			var remoteErr *RemoteError
+			var responseErr ResponseError
+			if !errors.As(err, &remoteErr) || remoteErr.Code != "404100" || remoteErr.Message != "订单不存在" || remoteErr.HttpCode != http.StatusNotFound || !errors.As(err, &responseErr) {
+				t.Fatalf("%s: err:%v", protocol, err)
+			}
+		}
+		server.Close()
+	}
+}
+
+func TestEnvelopeLegacy(t *testing.T) {
+	for protocol, body := range envelopeBodies(`{"id":"1","amount":9.5}`) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ContentTypeJson)
+			w.Write([]byte(body))
+		}))
+		client := NewClientProtocol(http.MethodGet, server.URL)
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(legacyEnvelopeMiddlewares[protocol])
+		var out envelopeOrder
+		err := client.Do(nil, &out)
+		server.Close()
+		if err != nil || out.Id != "1" || out.Amount != 9.5 {
+			t.Fatalf("%s: %+v,err:%v", protocol, out, err)
+		}
+	}
+}
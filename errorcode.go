@@ -0,0 +1,154 @@
+package apihttpprotocol
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrorCodeDefinition 业务码定义
+type ErrorCodeDefinition struct {
+	Code       string            `json:"code" yaml:"code"`
+	HttpStatus int               `json:"httpStatus" yaml:"httpStatus"` // 错误未指定状态码时使用，为0时服务端按200输出
+	Messages   map[string]string `json:"messages" yaml:"messages"`     // 语言(如 zh-CN、en)到信息
+}
+
+// ErrorCodeRegistry 业务码注册表，服务端按 Accept-Language 输出对应语言的信息
+type ErrorCodeRegistry struct {
+	DefaultLanguage string // 请求语言都未匹配时使用
+	mutex           sync.RWMutex
+	codes           map[string]ErrorCodeDefinition
+}
+
+func NewErrorCodeRegistry(defaultLanguage string) *ErrorCodeRegistry {
+	return &ErrorCodeRegistry{
+		DefaultLanguage: defaultLanguage,
+		codes:           map[string]ErrorCodeDefinition{},
+	}
+}
+
+// DefaultErrorCodeRegistry 服务端信封中间件使用的注册表
+var DefaultErrorCodeRegistry = NewErrorCodeRegistry("zh-CN")
+
+// Register 注册业务码，同码覆盖
+func (r *ErrorCodeRegistry) Register(definitions ...ErrorCodeDefinition) *ErrorCodeRegistry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, definition := range definitions {
+		messages := make(map[string]string, len(definition.Messages))
+		for language, message := range definition.Messages {
+			messages[strings.ToLower(language)] = message
+		}
+		definition.Messages = messages
+		r.codes[definition.Code] = definition
+	}
+	return r
+}
+
+// Load 从 json/yaml 加载业务码列表
+func (r *ErrorCodeRegistry) Load(b []byte) (err error) {
+	definitions := make([]ErrorCodeDefinition, 0)
+	err = yaml.Unmarshal(b, &definitions)
+	if err != nil {
+		return errors.WithMessage(err, "load error codes")
+	}
+	r.Register(definitions...)
+	return nil
+}
+
+func (r *ErrorCodeRegistry) LoadFile(filename string) (err error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return errors.WithMessagef(r.Load(b), "file:%s", filename)
+}
+
+func (r *ErrorCodeRegistry) Get(code string) (definition ErrorCodeDefinition, ok bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	definition, ok = r.codes[code]
+	return definition, ok
+}
+
+// Message 按语言优先级查找信息，依次尝试完整匹配(en-US)、主语言(en)，最后使用默认语言
+func (r *ErrorCodeRegistry) Message(code string, languages ...string) (message string, ok bool) {
+	definition, ok := r.Get(code)
+	if !ok {
+		return "", false
+	}
+	for _, language := range languages {
+		language = strings.ToLower(language)
+		if message, ok = definition.Messages[language]; ok {
+			return message, true
+		}
+		if primary, _, found := strings.Cut(language, "-"); found {
+			if message, ok = definition.Messages[primary]; ok {
+				return message, true
+			}
+		}
+	}
+	message, ok = definition.Messages[strings.ToLower(r.DefaultLanguage)]
+	return message, ok
+}
+
+// NewError 创建已注册业务码的错误，使用注册的状态码及默认语言信息
+func (r *ErrorCodeRegistry) NewError(code string) *CodeError {
+	message, _ := r.Message(code)
+	definition, _ := r.Get(code)
+	return NewCodeError(code, message).WithHttpStatus(definition.HttpStatus)
+}
+
+// isRegisteredMessage 信息是否为业务码注册的某种语言信息(如 NewError 创建的错误)，此时仍按请求语言输出
+func (r *ErrorCodeRegistry) isRegisteredMessage(code string, message string) bool {
+	definition, ok := r.Get(code)
+	if !ok {
+		return false
+	}
+	for _, registered := range definition.Messages {
+		if registered == message {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAcceptLanguage 解析 Accept-Language，按权重从高到低返回语言，忽略 q=0 及 *
+func ParseAcceptLanguage(header string) (languages []string) {
+	type weighted struct {
+		language string
+		q        float64
+	}
+	items := make([]weighted, 0)
+	for _, part := range strings.Split(header, ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language = strings.TrimSpace(language)
+		if language == "" || language == "*" {
+			continue
+		}
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if q <= 0 {
+			continue
+		}
+		items = append(items, weighted{language: language, q: q})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+	for _, item := range items {
+		languages = append(languages, item.language)
+	}
+	return languages
+}
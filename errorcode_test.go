@@ -0,0 +1,85 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	languages := ParseAcceptLanguage("fr;q=0.5, en-US,zh-CN;q=0.8, *;q=0.1, de;q=0")
+	if !reflect.DeepEqual(languages, []string{"en-US", "zh-CN", "fr"}) {
+		t.Fatalf("languages:%v", languages)
+	}
+}
+
+func TestErrorCodeRegistry(t *testing.T) {
+	registry := NewErrorCodeRegistry("zh-CN")
+	err := registry.Load([]byte(`
+- code: "404100"
+  httpStatus: 404
+  messages:
+    zh-CN: 订单不存在
+    en: order not found
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		languages []string
+		message   string
+	}{
+		{[]string{"en-GB"}, "order not found"},
+		{[]string{"ja", "zh-cn"}, "订单不存在"},
+		{nil, "订单不存在"},
+	}
+	for _, c := range cases {
+		if message, _ := registry.Message("404100", c.languages...); message != c.message {
+			t.Fatalf("languages:%v,message:%s", c.languages, message)
+		}
+	}
+	codeErr := registry.NewError("404100")
+	if codeErr.HttpStatus != http.StatusNotFound || codeErr.Message != "订单不存在" {
+		t.Fatalf("error:%+v", codeErr)
+	}
+
+	DefaultErrorCodeRegistry = registry
+	defer func() { DefaultErrorCodeRegistry = NewErrorCodeRegistry("zh-CN") }()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handlerErrors := map[string]error{
+		"registered": registry.NewError("404100"),
+		"empty":      NewCodeError("404100", ""),
+		"specific":   NewCodeError("404100", "order 12 not found"),
+	}
+	engine.GET("/order", NewGinHander(func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		p.SetLog(LogIgnore{})
+		return p
+	}, func(in map[string]any) (out any, err error) {
+		return nil, handlerErrors[in["case"].(string)]
+	}))
+	for name, message := range map[string]string{
+		"registered": "order not found",    // 注册表创建的错误按请求语言输出
+		"empty":      "order not found",    // 错误无展示信息时使用注册信息
+		"specific":   "order 12 not found", // 处理器给出的具体信息优先
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/order?case="+name, nil)
+		req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		rsp := Response{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusNotFound || rsp.Code != "404100" || rsp.Message != message {
+			t.Fatalf("%s: status:%d,body:%s", name, w.Code, strings.TrimSpace(w.Body.String()))
+		}
+	}
+}
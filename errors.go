@@ -0,0 +1,130 @@
+package apihttpprotocol
+
+import (
+	"github.com/pkg/errors"
+)
+
+// 带有http状态码的错误信息接口，服务端输出错误时使用该状态码
+type ErrorWithHttpStatus interface {
+	GetHttpStatus() int
+	error
+}
+
+// 带有对外展示信息的错误接口，生产模式下只输出该信息
+type ErrorWithMessage interface {
+	GetMessage() string
+	error
+}
+
+// 带有结构化详情的错误接口，服务端输出到响应的 details 字段
+type ErrorWithDetails interface {
+	GetDetails() any
+	error
+}
+
+var (
+	ProductionMode = false // 生产模式，响应中隐藏内部错误信息(Internal、包装链上下文、未带业务码错误的原始信息)
+)
+
+// CodeError 带业务码的错误
+type CodeError struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`           // 对外展示信息
+	Details    any    `json:"details,omitempty"` // 结构化详情，如字段校验错误列表
+	HttpStatus int    `json:"-"`                 // 为0时服务端按200输出
+	Internal   string `json:"-"`                 // 内部信息，只记录日志，生产模式下不输出
+	cause      error
+}
+
+func NewCodeError(code string, message string) *CodeError {
+	return &CodeError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WrapCodeError 包装底层错误，可通过 errors.Is/As 获取底层错误
+func WrapCodeError(err error, code string, message string) *CodeError {
+	e := NewCodeError(code, message)
+	e.cause = err
+	return e
+}
+
+func (e *CodeError) WithHttpStatus(httpStatus int) *CodeError {
+	e.HttpStatus = httpStatus
+	return e
+}
+
+func (e *CodeError) WithDetails(details any) *CodeError {
+	e.Details = details
+	return e
+}
+
+func (e *CodeError) WithInternal(internal string) *CodeError {
+	e.Internal = internal
+	return e
+}
+
+func (e *CodeError) Error() string {
+	s := e.Message
+	if e.Internal != "" {
+		s = s + ":" + e.Internal
+	}
+	if e.cause != nil {
+		s = s + ":" + e.cause.Error()
+	}
+	return s
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.cause
+}
+
+func (e *CodeError) GetCode() string {
+	return e.Code
+}
+
+func (e *CodeError) GetMessage() string {
+	return e.Message
+}
+
+func (e *CodeError) GetDetails() any {
+	return e.Details
+}
+
+func (e *CodeError) GetHttpStatus() int {
+	return e.HttpStatus
+}
+
+// getHttpStatus 沿错误链查找http状态码，未找到返回0
+func getHttpStatus(err error) (httpStatus int) {
+	var httpErr ErrorWithHttpStatus
+	if errors.As(err, &httpErr) {
+		return httpErr.GetHttpStatus()
+	}
+	return 0
+}
+
+// getBusinessMessage 提取对外信息，生产模式下只输出 ErrorWithMessage 的展示信息
+func getBusinessMessage(err error) (message string) {
+	if err == nil {
+		return BusinessMessage_Success
+	}
+	if !ProductionMode {
+		return err.Error()
+	}
+	var messageErr ErrorWithMessage
+	if errors.As(err, &messageErr) {
+		return messageErr.GetMessage()
+	}
+	return BusinessMessage_Fail
+}
+
+// getBusinessDetails 沿错误链查找结构化详情
+func getBusinessDetails(err error) (details any) {
+	var detailsErr ErrorWithDetails
+	if errors.As(err, &detailsErr) {
+		return detailsErr.GetDetails()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+func TestCodeErrorChain(t *testing.T) {
+	cause := io.ErrUnexpectedEOF
+	codeErr := WrapCodeError(cause, "400100", "invalid order").
+		WithHttpStatus(http.StatusBadRequest).
+		WithInternal("order id empty").
+		WithDetails(map[string]string{"field": "id"})
+	err := errors.WithMessage(codeErr, "create order")
+
+	if code := getBusinessCode(err); code != "400100" {
+		t.Fatalf("code:%s", code)
+	}
+	if status := getHttpStatus(err); status != http.StatusBadRequest {
+		t.Fatalf("status:%d", status)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("cause lost")
+	}
+	if details := getBusinessDetails(err); details == nil {
+		t.Fatal("details lost")
+	}
+	if msg := getBusinessMessage(err); msg != "create order: invalid order:order id empty:unexpected EOF" {
+		t.Fatalf("message:%s", msg)
+	}
+	ProductionMode = true
+	defer func() { ProductionMode = false }()
+	if msg := getBusinessMessage(err); msg != "invalid order" {
+		t.Fatalf("production message:%s", msg)
+	}
+	if msg := getBusinessMessage(errors.New("sql: connection refused")); msg != BusinessMessage_Fail {
+		t.Fatalf("production plain message:%s", msg)
+	}
+}
+
+func TestCodeErrorEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	protoFn := func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		p.SetLog(LogIgnore{})
+		return p
+	}
+	engine.POST("/order", NewGinHander(protoFn, func(in map[string]any) (out any, err error) {
+		err = NewCodeError("400100", "invalid order").WithHttpStatus(http.StatusBadRequest).WithInternal("id empty").WithDetails([]string{"id"})
+		return nil, errors.WithMessage(err, "validate")
+	}))
+	ProductionMode = true
+	defer func() { ProductionMode = false }()
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(`{}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status:%d", w.Code)
+	}
+	rsp := Response{}
+	if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Code != "400100" || rsp.Message != "invalid order" {
+		t.Fatalf("response:%s", w.Body.String())
+	}
+	if details, ok := rsp.Details.([]any); !ok || len(details) != 1 || details[0] != "id" {
+		t.Fatalf("details:%v", rsp.Details)
+	}
+}
@@ -0,0 +1,185 @@
+package apihttpprotocol
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MetaData_Endpoint         = "endpoint"          // 最终采用的上游地址
+	MetaData_EndpointAttempts = "endpoint_attempts" // 各上游地址的请求结果 []EndpointAttempt
+)
+
+// EndpointAttempt 单个上游地址的请求结果
+type EndpointAttempt struct {
+	Endpoint string        `json:"endpoint"`
+	HttpCode int           `json:"httpCode"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Won      bool          `json:"won"`
+}
+
+type FailoverConfig struct {
+	Endpoints  []string      // 上游地址列表，如 http://10.0.0.1:8080，仅替换请求URL的 scheme 和 host，按顺序尝试
+	HedgeDelay time.Duration // >0 时开启对冲：该时间内未返回则向下一个地址并发请求，先成功者胜出并取消其它请求；仅用于幂等请求
+}
+
+type endpointResult struct {
+	index    int
+	response *CachedResponse
+	request  *http.Request
+	err      error
+	duration time.Duration
+}
+
+// failed 网络错误及5xx时切换下一个地址
+func (r endpointResult) failed() bool {
+	return r.err != nil || r.response.StatusCode >= http.StatusInternalServerError
+}
+
+// endpointRequest 复制请求并替换 scheme 和 host，地址带路径(如 https://b/api)时作为前缀拼接到请求路径前
+func endpointRequest(ctx context.Context, req *http.Request, endpoint string) (outReq *http.Request, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "endpoint:%s", endpoint)
+	}
+	outReq = req.Clone(ctx)
+	outReq.URL.Scheme = u.Scheme
+	outReq.URL.Host = u.Host
+	if base := strings.TrimSuffix(u.Path, "/"); base != "" {
+		outReq.URL.Path = base + "/" + strings.TrimPrefix(req.URL.Path, "/")
+		outReq.URL.RawPath = ""
+		if u.RawPath != "" || req.URL.RawPath != "" {
+			outReq.URL.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.TrimPrefix(req.URL.EscapedPath(), "/")
+		}
+	}
+	outReq.Host = ""
+	if req.GetBody != nil {
+		outReq.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return outReq, nil
+}
+
+// ResponseMiddleFailover 客户端多地址容错，网络错误或5xx时切换下一个地址；配置 HedgeDelay 时发送对冲请求。
+// 采用的地址及各地址请求结果记录在 MetaData_Endpoint、MetaData_EndpointAttempts 中，ResponseMiddleLog 会输出
+func ResponseMiddleFailover(config FailoverConfig) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		if len(config.Endpoints) == 0 {
+			return message.Next()
+		}
+		message.WrapDo(func(next DoFunc) DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				ctx, cancel := context.WithCancel(req.Context())
+				defer cancel() // 返回前已读取完body，取消未完成的请求
+				results := make(chan endpointResult, len(config.Endpoints))
+				attempts := make([]EndpointAttempt, 0, len(config.Endpoints))
+				launched, pending := 0, 0
+				launch := func() {
+					index := launched
+					launched++
+					pending++
+					go func() {
+						start := time.Now()
+						result := endpointResult{index: index}
+						result.request, result.err = endpointRequest(ctx, req, config.Endpoints[index])
+						if result.err == nil {
+							result.response, result.err = readEndpointResponse(next, result.request)
+						}
+						result.duration = time.Since(start)
+						results <- result
+					}()
+				}
+				var hedge <-chan time.Time
+				resetHedge := func() {
+					if config.HedgeDelay > 0 && launched < len(config.Endpoints) {
+						hedge = time.After(config.HedgeDelay)
+					} else {
+						hedge = nil
+					}
+				}
+				launch()
+				resetHedge()
+				var last endpointResult
+				for pending > 0 {
+					select {
+					case <-hedge:
+						launch()
+						resetHedge()
+						continue
+					case last = <-results:
+					}
+					pending--
+					attempt := EndpointAttempt{Endpoint: config.Endpoints[last.index], Duration: last.duration}
+					if last.response != nil {
+						attempt.HttpCode = last.response.StatusCode
+					}
+					if last.err != nil {
+						attempt.Error = last.err.Error()
+					}
+					if !last.failed() {
+						attempt.Won = true
+						attempts = append(attempts, attempt)
+						break
+					}
+					attempts = append(attempts, attempt)
+					if launched < len(config.Endpoints) {
+						launch()
+						resetHedge()
+					}
+				}
+				message.SetMetaData(MetaData_EndpointAttempts, attempts)
+				message.SetMetaData(MetaData_Endpoint, config.Endpoints[last.index])
+				if last.err != nil {
+					return nil, last.err
+				}
+				return last.response.toResponse(last.request), nil // 全部失败时返回最后一个5xx响应，由后续逻辑生成 ResponseError
+			}
+		})
+		return message.Next()
+	}
+}
+
+// readEndpointResponse 读取完整响应，避免取消 context 后无法读取胜出请求的body
+func readEndpointResponse(do DoFunc, req *http.Request) (cached *CachedResponse, err error) {
+	response, err := do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedResponse{
+		StatusCode: response.StatusCode,
+		Header:     response.Header.Clone(),
+		Body:       body,
+	}, nil
+}
+
+// formatEndpointAttempts 日志输出格式
+func formatEndpointAttempts(attempts []EndpointAttempt) string {
+	items := make([]string, 0, len(attempts))
+	for _, attempt := range attempts {
+		item := attempt.Endpoint + "=" + attempt.Duration.String()
+		if attempt.Error != "" {
+			item += "(" + attempt.Error + ")"
+		} else {
+			item += "(" + http.StatusText(attempt.HttpCode) + ")"
+		}
+		if attempt.Won {
+			item += "*"
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ";")
+}
@@ -0,0 +1,79 @@
+package apihttpprotocol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFailover(t *testing.T) {
+	newServer := func(status int, delay time.Duration, name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			w.Header().Set("Content-Type", ContentTypeJson)
+			w.WriteHeader(status)
+			w.Write([]byte(`{"code":"0","message":"success","data":"` + name + `"}`))
+		}))
+	}
+	broken := newServer(http.StatusServiceUnavailable, 0, "broken")
+	defer broken.Close()
+	slow := newServer(http.StatusOK, time.Second, "slow")
+	defer slow.Close()
+	fast := newServer(http.StatusOK, 0, "fast")
+	defer fast.Close()
+
+	call := func(config FailoverConfig) (out string, endpoint any) {
+		client := NewClientProtocol("GET", config.Endpoints[0]+"/read")
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(ResponseMiddleFailover(config), ResponseMiddleCodeMessageForClient)
+		err := client.Do(nil, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		endpoint, _ = client.Response().MetaData.Get(MetaData_Endpoint)
+		return out, endpoint
+	}
+
+	out, endpoint := call(FailoverConfig{Endpoints: []string{broken.URL, fast.URL}})
+	if out != "fast" || endpoint != fast.URL {
+		t.Fatalf("failover expected fast,got out:%s,endpoint:%v", out, endpoint)
+	}
+
+	start := time.Now()
+	out, endpoint = call(FailoverConfig{Endpoints: []string{slow.URL, fast.URL}, HedgeDelay: 20 * time.Millisecond})
+	if out != "fast" || endpoint != fast.URL {
+		t.Fatalf("hedge expected fast,got out:%s,endpoint:%v", out, endpoint)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("hedged request should not wait for slow endpoint")
+	}
+
+	prefixed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"0","message":"success","data":"` + r.URL.Path + `"}`))
+	}))
+	defer prefixed.Close()
+	client := NewClientProtocol("GET", broken.URL+"/read")
+	client.SetLog(LogIgnore{})
+	client.Response().AddMiddleware(ResponseMiddleFailover(FailoverConfig{Endpoints: []string{broken.URL, prefixed.URL + "/api/"}}), ResponseMiddleCodeMessageForClient)
+	if err := client.Do(nil, &out); err != nil || out != "/api/read" { // 地址路径作为前缀
+		t.Fatalf("endpoint path expected /api/read,got %s,err:%v", out, err)
+	}
+
+	log := &recoveryLog{}
+	client = NewClientProtocol("GET", broken.URL+"/read")
+	client.SetLog(log)
+	client.Response().AddMiddleware(ResponseMiddleLog, ResponseMiddleFailover(FailoverConfig{Endpoints: []string{broken.URL, broken.URL}}), ResponseMiddleCodeMessageForClient)
+	if err := client.Do(nil, &out); err == nil {
+		t.Fatal("expected all endpoints fail")
+	}
+	if len(log.errors) != 1 || strings.Count(log.errors[0], broken.URL) < 2 || !strings.Contains(log.errors[0], "endpoints:") {
+		t.Fatalf("failover attempts should be logged on error:%v", log.errors)
+	}
+}
@@ -0,0 +1,413 @@
+package apihttpprotocol
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR HTTP Archive 1.2，可导入浏览器开发者工具或回放工具
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // 毫秒
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []HARNameValue `json:"params,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARTimings 各阶段耗时(毫秒)，-1 表示不适用
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+var (
+	HARRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"} // HARCollector.RedactHeaders 为 nil 时脱敏的请求/响应头
+	HARRedactedValue = "[REDACTED]"
+)
+
+// HARCollector 收集 HAR 记录，超过 MaxEntries 时丢弃最早的记录，需全局共享
+type HARCollector struct {
+	MaxEntries    int
+	RedactHeaders []string // 记录时脱敏的请求/响应头(Cookie、Set-Cookie 同时脱敏 cookies)，为 nil 时使用 HARRedactHeaders，设为空切片则不脱敏
+	mutex         sync.Mutex
+	entries       []HAREntry
+}
+
+func NewHARCollector(maxEntries int) *HARCollector {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &HARCollector{MaxEntries: maxEntries}
+}
+
+func (c *HARCollector) Add(entry HAREntry) {
+	redactHeaders := c.RedactHeaders
+	if redactHeaders == nil {
+		redactHeaders = HARRedactHeaders
+	}
+	entry = redactHAREntry(entry, redactHeaders)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = append(c.entries, entry)
+	if overflow := len(c.entries) - c.MaxEntries; overflow > 0 {
+		c.entries = append([]HAREntry(nil), c.entries[overflow:]...)
+	}
+}
+
+func (c *HARCollector) Entries() []HAREntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]HAREntry(nil), c.entries...)
+}
+
+func (c *HARCollector) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = nil
+}
+
+func (c *HARCollector) HAR() HAR {
+	return HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "apihttpprotocol", Version: "1.0"},
+		Entries: c.Entries(),
+	}}
+}
+
+// WriteFile 写入 .har 文件
+func (c *HARCollector) WriteFile(filename string) (err error) {
+	b, err := json.MarshalIndent(c.HAR(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
+
+// ServeHTTP 以 HAR 格式输出已收集的记录，gin 中可通过 gin.WrapH 注册
+func (c *HARCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := json.Marshal(c.HAR())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeJson)
+	w.Header().Set("Content-Disposition", `attachment; filename="traffic.har"`)
+	w.Write(b)
+}
+
+// harTrace 通过 httptrace 记录客户端请求各阶段时间点
+type harTrace struct {
+	mutex                  sync.Mutex
+	start                  time.Time
+	dnsStart, dnsDone      time.Time
+	connectStart, connDone time.Time
+	tlsStart, tlsDone      time.Time
+	gotConn                time.Time
+	wroteRequest           time.Time
+	firstByte              time.Time
+}
+
+func (t *harTrace) set(field *time.Time) func() {
+	return func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		if field.IsZero() {
+			*field = time.Now()
+		}
+	}
+}
+
+func (t *harTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.set(&t.dnsStart)() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone)() },
+		ConnectStart:         func(string, string) { t.set(&t.connectStart)() },
+		ConnectDone:          func(string, string, error) { t.set(&t.connDone)() },
+		TLSHandshakeStart:    t.set(&t.tlsStart),
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone)() },
+		GotConn:              func(httptrace.GotConnInfo) { t.set(&t.gotConn)() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest)() },
+		GotFirstResponseByte: t.set(&t.firstByte),
+	}
+}
+
+func harDuration(from time.Time, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return -1
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
+
+// timings 未经过网络(如服务端或缓存命中)时，整体耗时计入 wait
+func (t *harTrace) timings(end time.Time) HARTimings {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.firstByte.IsZero() {
+		return HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 0, Wait: harDuration(t.start, end), Receive: 0}
+	}
+	timings := HARTimings{
+		Blocked: harDuration(t.start, t.dnsStart),
+		DNS:     harDuration(t.dnsStart, t.dnsDone),
+		Connect: harDuration(t.connectStart, t.connDone),
+		SSL:     harDuration(t.tlsStart, t.tlsDone),
+		Send:    harDuration(t.gotConn, t.wroteRequest),
+		Wait:    harDuration(t.wroteRequest, t.firstByte),
+		Receive: harDuration(t.firstByte, end),
+	}
+	if t.dnsStart.IsZero() && t.connectStart.IsZero() { // 复用连接
+		timings.Blocked = harDuration(t.start, t.gotConn)
+	}
+	for _, v := range []*float64{&timings.Send, &timings.Wait, &timings.Receive} {
+		if *v < 0 {
+			*v = 0
+		}
+	}
+	return timings
+}
+
+func harNameValues(values map[string][]string) []HARNameValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]HARNameValue, 0, len(values))
+	for _, name := range names {
+		for _, value := range values[name] {
+			items = append(items, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return items
+}
+
+func harCookies(cookies []*http.Cookie) []HARNameValue {
+	items := make([]HARNameValue, 0, len(cookies))
+	for _, cookie := range cookies {
+		items = append(items, HARNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	return items
+}
+
+// redactHAREntry 敏感请求/响应头的值替换为 HARRedactedValue，脱敏 Cookie、Set-Cookie 时同时脱敏对应的 cookies
+func redactHAREntry(entry HAREntry, headers []string) HAREntry {
+	if len(headers) == 0 {
+		return entry
+	}
+	redact := map[string]bool{}
+	for _, header := range headers {
+		redact[http.CanonicalHeaderKey(header)] = true
+	}
+	isSensitive := func(name string) bool {
+		return redact[http.CanonicalHeaderKey(name)]
+	}
+	all := func(name string) bool {
+		return true
+	}
+	entry.Request.Headers = redactHARNameValues(entry.Request.Headers, isSensitive)
+	entry.Response.Headers = redactHARNameValues(entry.Response.Headers, isSensitive)
+	if redact["Cookie"] {
+		entry.Request.Cookies = redactHARNameValues(entry.Request.Cookies, all)
+	}
+	if redact["Set-Cookie"] {
+		entry.Response.Cookies = redactHARNameValues(entry.Response.Cookies, all)
+	}
+	return entry
+}
+
+// redactHARNameValues 返回脱敏后的副本，不修改原记录
+func redactHARNameValues(items []HARNameValue, match func(name string) bool) []HARNameValue {
+	if items == nil {
+		return nil
+	}
+	redacted := make([]HARNameValue, len(items))
+	for i, item := range items {
+		if match(item.Name) {
+			item.Value = HARRedactedValue
+		}
+		redacted[i] = item
+	}
+	return redacted
+}
+
+func harHttpVersion(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+// NewHAREntry 将请求及响应转换为 HAR 记录，会读取并恢复两者的 body
+func NewHAREntry(req *http.Request, response *http.Response, started time.Time, timings HARTimings) (entry HAREntry) {
+	requestBody := harReadBody(&req.Body)
+	rawUrl := req.URL.String()
+	if !req.URL.IsAbs() { // 服务端收到的请求只有路径
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		rawUrl = scheme + "://" + req.Host + req.URL.RequestURI()
+	}
+	entry = HAREntry{
+		StartedDateTime: started.Format("2006-01-02T15:04:05.000Z07:00"),
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         rawUrl,
+			HTTPVersion: harHttpVersion(req.Proto),
+			Cookies:     harCookies(req.Cookies()),
+			Headers:     harNameValues(req.Header),
+			QueryString: harNameValues(req.URL.Query()),
+			HeadersSize: -1,
+			BodySize:    len(requestBody),
+		},
+		Timings: timings,
+	}
+	if len(requestBody) > 0 {
+		postData := &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(requestBody)}
+		if strings.Contains(postData.MimeType, ContentTypeForm) {
+			values, _ := url.ParseQuery(string(requestBody))
+			postData.Params = harNameValues(values)
+		}
+		entry.Request.PostData = postData
+	}
+	if response != nil {
+		responseBody := harReadBody(&response.Body)
+		content := HARContent{Size: len(responseBody), MimeType: response.Header.Get("Content-Type"), Text: string(responseBody)}
+		if !utf8.Valid(responseBody) {
+			content.Text, content.Encoding = base64.StdEncoding.EncodeToString(responseBody), "base64"
+		}
+		entry.Response = HARResponse{
+			Status:      response.StatusCode,
+			StatusText:  http.StatusText(response.StatusCode),
+			HTTPVersion: harHttpVersion(response.Proto),
+			Cookies:     harCookies(response.Cookies()),
+			Headers:     harNameValues(response.Header),
+			Content:     content,
+			RedirectURL: response.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(responseBody),
+		}
+	}
+	for _, v := range []float64{timings.Blocked, timings.DNS, timings.Connect, timings.Send, timings.Wait, timings.Receive} {
+		if v > 0 {
+			entry.Time += v
+		}
+	}
+	return entry
+}
+
+func harReadBody(body *io.ReadCloser) []byte {
+	if *body == nil {
+		return nil
+	}
+	b, _ := io.ReadAll(*body)
+	(*body).Close()
+	*body = io.NopCloser(strings.NewReader(string(b)))
+	return b
+}
+
+// ResponseMiddleHAR 将每次请求的复制请求/响应(GetDuplicateRequest/GetDuplicateResponse)转换为 HAR 记录，客户端和服务端均可使用
+func ResponseMiddleHAR(collector *HARCollector) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		trace := &harTrace{start: time.Now()}
+		message.WrapDo(func(next DoFunc) DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				return next(req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace())))
+			}
+		})
+		err = message.Next()
+		timings := trace.timings(time.Now())
+		requestMessage, ok := message.GetRequestMessage()
+		if !ok {
+			return err
+		}
+		req, ok := requestMessage.GetDuplicateRequest()
+		if !ok {
+			return err
+		}
+		response, _ := message.GetDuplicateResponse()
+		if response != nil && response.Request != nil && response.Request.URL != nil && response.Request.URL.IsAbs() {
+			req.URL = response.Request.URL // 服务发现、多地址容错后实际请求的地址
+		}
+		entry := NewHAREntry(req, response, trace.start, timings)
+		if err != nil {
+			entry.Comment = err.Error()
+		}
+		collector.Add(entry)
+		return err
+	}
+}
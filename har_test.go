@@ -0,0 +1,89 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHAR(t *testing.T) {
+	serverCollector := NewHARCollector(10)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	protoFn := func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(ResponseMiddleHAR(serverCollector), ResponseMiddleCodeMessageForServer)
+		p.SetLog(LogIgnore{})
+		return p
+	}
+	engine.POST("/echo", func(c *gin.Context) {
+		c.SetCookie("session", "server-secret", 0, "/", "", false, true)
+		NewGinHander(protoFn, func(in map[string]any) (out map[string]any, err error) {
+			return in, nil
+		})(c)
+	})
+	engine.GET("/har", gin.WrapH(serverCollector))
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	clientCollector := NewHARCollector(10)
+	client := NewClientProtocol("POST", server.URL+"/echo?from=test")
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeJson)
+	client.SetHeader("Authorization", "Bearer client-secret")
+	client.SetHeader("Cookie", "session=cookie-secret")
+	client.Response().AddMiddleware(ResponseMiddleHAR(clientCollector), ResponseMiddleCodeMessageForClient)
+	var out map[string]any
+	err := client.Do(map[string]any{"name": "har"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := clientCollector.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry,got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"name":"har"}` || entry.Response.Status != 200 || len(entry.Request.QueryString) != 1 {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if entry.Timings.Wait < 0 || entry.Response.Content.Text == "" {
+		t.Fatalf("unexpected timings or content %+v", entry)
+	}
+
+	var har HAR
+	client = NewClientProtocol("GET", server.URL+"/har")
+	client.SetLog(LogIgnore{})
+	err = client.Do(nil, &har)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 || har.Log.Entries[0].Request.URL != server.URL+"/echo?from=test" {
+		t.Fatalf("unexpected server har %+v", har.Log)
+	}
+	for name, collector := range map[string]*HARCollector{"client": clientCollector, "server": serverCollector} {
+		b, _ := json.Marshal(collector.HAR())
+		if strings.Contains(string(b), "secret") || !strings.Contains(string(b), HARRedactedValue) {
+			t.Fatalf("%s har should redact sensitive headers:%s", name, b)
+		}
+	}
+	collector := &HARCollector{MaxEntries: 1, RedactHeaders: []string{}}
+	collector.Add(HAREntry{Request: HARRequest{Headers: []HARNameValue{{Name: "Authorization", Value: "raw"}}}})
+	if collector.Entries()[0].Request.Headers[0].Value != "raw" {
+		t.Fatal("empty RedactHeaders should keep headers")
+	}
+
+	filename := filepath.Join(t.TempDir(), "client.har")
+	err = clientCollector.WriteFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(filename)
+	if !json.Valid(b) {
+		t.Fatal("har file should be valid json")
+	}
+}
@@ -0,0 +1,250 @@
+package apihttpprotocol
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JWK json web key，目前只支持RSA公钥
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (k JWK) RsaPublicKey() (publicKey *rsa.PublicKey, err error) {
+	if k.Kty != "RSA" {
+		return nil, errors.Errorf("unsupported jwk kty:%s", k.Kty)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, errors.WithMessage(err, "jwk n")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, errors.WithMessage(err, "jwk e")
+	}
+	publicKey = &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+	return publicKey, nil
+}
+
+// JWKSet 密钥集合，可从本地文件或内存数据加载
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewJWKSet(data []byte) (set *JWKSet, err error) {
+	set = &JWKSet{}
+	err = json.Unmarshal(data, set)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse jwks")
+	}
+	return set, nil
+}
+
+func LoadJWKSFile(filename string) (set *JWKSet, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewJWKSet(data)
+}
+
+// RsaPublicKey 根据kid获取公钥，kid为空且只有一个密钥时返回该密钥
+func (s *JWKSet) RsaPublicKey(kid string) (publicKey *rsa.PublicKey, err error) {
+	if s == nil {
+		return nil, errors.New("jwks is nil")
+	}
+	for _, key := range s.Keys {
+		if key.Kid == kid || (kid == "" && len(s.Keys) == 1) {
+			return key.RsaPublicKey()
+		}
+	}
+	return nil, errors.Errorf("jwk not found,kid:%s", kid)
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256,
+	"HS384": crypto.SHA384,
+	"HS512": crypto.SHA512,
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// SignJWTHmac 使用HMAC签发令牌，alg 为 HS256/HS384/HS512
+func SignJWTHmac(claims map[string]any, alg string, secret []byte) (token string, err error) {
+	hash, ok := jwtHashes[alg]
+	if !ok || !strings.HasPrefix(alg, "HS") {
+		return "", errors.Errorf("unsupported jwt alg:%s", alg)
+	}
+	header, err := json.Marshal(jwtHeader{Alg: alg, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(hash.New, secret)
+	mac.Write([]byte(signingInput))
+	token = signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return token, nil
+}
+
+// JWTAuthenticator JWT令牌校验，HS系列使用 HmacSecret，RS系列使用 JWKS
+type JWTAuthenticator struct {
+	HmacSecret []byte
+	JWKS       *JWKSet
+	Issuer     string        // 不为空时校验iss
+	Audience   string        // 不为空时校验aud
+	Leeway     time.Duration // exp、nbf 允许的时钟偏差
+}
+
+func (a JWTAuthenticator) Authenticate(token string) (principal *Principal, err error) {
+	claims, err := a.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	principal = &Principal{
+		Type:    PrincipalType_JWT,
+		Subject: claimString(claims, "sub"),
+		Claims:  claims,
+	}
+	return principal, nil
+}
+
+// Verify 校验签名及标准声明，返回全部声明
+func (a JWTAuthenticator) Verify(token string) (claims map[string]any, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed jwt")
+	}
+	var header jwtHeader
+	err = decodeJWTPart(parts[0], &header)
+	if err != nil {
+		return nil, errors.WithMessage(err, "jwt header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.WithMessage(err, "jwt signature")
+	}
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return nil, errors.Errorf("unsupported jwt alg:%s", header.Alg)
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+	switch {
+	case strings.HasPrefix(header.Alg, "HS"):
+		if len(a.HmacSecret) == 0 {
+			return nil, errors.Errorf("jwt alg %s not allowed", header.Alg)
+		}
+		mac := hmac.New(hash.New, a.HmacSecret)
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, ErrSignatureMismatch
+		}
+	default:
+		publicKey, err := a.JWKS.RsaPublicKey(header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		hasher := hash.New()
+		hasher.Write(signingInput)
+		err = rsa.VerifyPKCS1v15(publicKey, hash, hasher.Sum(nil), signature)
+		if err != nil {
+			return nil, ErrSignatureMismatch
+		}
+	}
+	claims = map[string]any{}
+	err = decodeJWTPart(parts[1], &claims)
+	if err != nil {
+		return nil, errors.WithMessage(err, "jwt claims")
+	}
+	err = a.validateClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a JWTAuthenticator) validateClaims(claims map[string]any) (err error) {
+	now := time.Now()
+	if exp, ok := claimTime(claims, "exp"); ok && now.After(exp.Add(a.Leeway)) {
+		return errors.New("jwt expired")
+	}
+	if nbf, ok := claimTime(claims, "nbf"); ok && now.Add(a.Leeway).Before(nbf) {
+		return errors.New("jwt not valid yet")
+	}
+	if a.Issuer != "" && claimString(claims, "iss") != a.Issuer {
+		return errors.New("jwt issuer mismatch")
+	}
+	if a.Audience != "" && !claimContains(claims, "aud", a.Audience) {
+		return errors.New("jwt audience mismatch")
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, dst any) (err error) {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	return decoder.Decode(dst)
+}
+
+func claimString(claims map[string]any, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+func claimTime(claims map[string]any, name string) (t time.Time, ok bool) {
+	number, ok := claims[name].(json.Number)
+	if !ok {
+		return t, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return t, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+func claimContains(claims map[string]any, name string, value string) bool {
+	switch v := claims[name].(type) {
+	case string:
+		return v == value
+	case []any:
+		for _, item := range v {
+			if s, _ := item.(string); s == value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -7,7 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -15,8 +18,9 @@ import (
 )
 
 const (
-// MetaData_HttpCode = "httpCode"
-// MetaData_RequestID = "requestID"
+	// MetaData_HttpCode = "httpCode"
+	// MetaData_RequestID = "requestID"
+	MetaData_ClientIP = "client_ip"
 )
 
 type MetaData map[string]any
@@ -165,7 +169,9 @@ type ResponseMessage struct {
 	ResponseError     error           // 记录返回错误
 	requestMessage    *RequestMessage // 请求消息，用于在中间件中获取原始请求参数(在response里面,这个参数才有值)
 	duplicateResponse *http.Response
-	HttpCode          int `json:"httpCode"` // 响应状态码
+	HttpCode          int                        `json:"httpCode"` // 响应状态码
+	doWrappers        []func(next DoFunc) DoFunc // 客户端网络请求包装
+	innerDoWrappers   []func(next DoFunc) DoFunc // 最内层网络请求包装，见 WrapDoInner
 }
 
 func (msg ResponseMessage) GetBusinessCode() string {
@@ -174,14 +180,29 @@ func (msg ResponseMessage) GetBusinessCode() string {
 
 var (
 	BusinessMessage_Success = "success"
+	BusinessMessage_Fail    = "internal error" // 生产模式下未带展示信息的错误统一输出
 )
 
+// GetBusinessMessage 错误自带展示信息时优先使用；否则业务码已在 DefaultErrorCodeRegistry 注册时，按请求头 Accept-Language 输出对应语言的信息
 func (msg ResponseMessage) GetBusinessMessage() string {
-	s := BusinessMessage_Success
-	if msg.ResponseError != nil {
-		s = msg.ResponseError.Error()
+	code := msg.GetBusinessCode()
+	var messageErr ErrorWithMessage
+	if errors.As(msg.ResponseError, &messageErr) && messageErr.GetMessage() != "" && !DefaultErrorCodeRegistry.isRegisteredMessage(code, messageErr.GetMessage()) {
+		return getBusinessMessage(msg.ResponseError) // 处理器给出的具体信息优先于注册的通用信息
 	}
-	return s
+	languages := make([]string, 0)
+	if msg.requestMessage != nil {
+		languages = ParseAcceptLanguage(msg.requestMessage.GetHeader("Accept-Language"))
+	}
+	message, ok := DefaultErrorCodeRegistry.Message(code, languages...)
+	if ok {
+		return message
+	}
+	return getBusinessMessage(msg.ResponseError)
+}
+
+func (msg ResponseMessage) GetBusinessDetails() any {
+	return getBusinessDetails(msg.ResponseError)
 }
 
 // 带有错误码的错误信息接口
@@ -190,39 +211,26 @@ type ErrorWithCode interface {
 	error
 }
 
-// GetBusinessCode 提取业务码，如果错误实现了ErrorWithCode接口，则返回其代码；否则返回默认的失败码。
+// GetBusinessCode 提取业务码，如果错误链上有实现ErrorWithCode接口的错误，则返回其代码；否则返回默认的失败码。
 func getBusinessCode(err error) (code string) {
 	if err == nil {
 		return Business_Code_Success
 	}
-	switch v := err.(type) {
-	case ErrorWithCode:
-		return v.GetCode()
-	default:
-		return Business_Code_Fail
+	var codeErr ErrorWithCode
+	if errors.As(err, &codeErr) {
+		return codeErr.GetCode()
 	}
+	return Business_Code_Fail
 }
 
 func (m *RequestMessage) ToRequest() (req *http.Request, err error) {
-	var buf *bytes.Buffer
 	var httpReq *http.Request
 	if m.GoStructRef != nil {
-		switch ref := m.GoStructRef.(type) {
-		case []byte:
-			buf = bytes.NewBuffer(ref)
-		case json.RawMessage:
-			buf = bytes.NewBuffer(ref)
-		case string:
-			buf = bytes.NewBufferString(ref)
-		default:
-			b, err := json.Marshal(m.GoStructRef)
-			if err != nil {
-				err = errors.WithMessagef(err, `json.Marshal(%v)`, m.GoStructRef)
-				return nil, err
-			}
-			buf = bytes.NewBuffer(b)
+		body, err := m.EncodeBody()
+		if err != nil {
+			return nil, err
 		}
-		httpReq, err = http.NewRequest(m.Method, m.URL, buf)
+		httpReq, err = http.NewRequest(m.Method, m.URL, bytes.NewBuffer(body))
 		if err != nil {
 			return nil, err
 		}
@@ -236,6 +244,37 @@ func (m *RequestMessage) ToRequest() (req *http.Request, err error) {
 	return httpReq, nil
 }
 
+// EncodeBody 将GoStructRef编码为请求体，[]byte、string等原始数据直接使用，其它按请求Content-Type编码
+func (m *RequestMessage) EncodeBody() (body []byte, err error) {
+	switch ref := m.GoStructRef.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return ref, nil
+	case json.RawMessage:
+		return ref, nil
+	case string:
+		return []byte(ref), nil
+	default:
+		codec := m.GetCodec()
+		body, err = codec.Marshal(m.GoStructRef)
+		if err != nil {
+			err = errors.WithMessagef(err, `%s Marshal(%v)`, codec.ContentType(), m.GoStructRef)
+			return nil, err
+		}
+		return body, nil
+	}
+}
+
+// GetCodec 根据请求头Content-Type获取请求体编解码器，未设置或未注册时使用默认编解码器(json)
+func (m *RequestMessage) GetCodec() (codec Codec) {
+	codec, ok := GetCodec(m.GetHeader("Content-Type"))
+	if !ok {
+		codec = DefaultCodec()
+	}
+	return codec
+}
+
 func (m *RequestMessage) GetDuplicateRequest() (duplicateRequest *http.Request, exists bool) {
 	if m.duplicateRequest == nil {
 		return nil, false
@@ -256,6 +295,105 @@ func (m *RequestMessage) SetDuplicateRequest(reqest *http.Request) (err error) {
 	return nil
 }
 
+// SetHttpRequest 使用http请求填充请求消息(方法、地址、请求头、原始请求体)，服务端在执行中间件前调用，便于中间件在解码前完成签名校验、鉴权等
+func (m *RequestMessage) SetHttpRequest(req *http.Request) (err error) {
+	err = m.SetDuplicateRequest(req)
+	if err != nil {
+		return err
+	}
+	m.Method = req.Method
+	m.URL = req.URL.String()
+	if m.Headers == nil {
+		m.Headers = http.Header{}
+	}
+	for k, v := range req.Header {
+		m.Headers[k] = append([]string{}, v...)
+	}
+	m.SetMetaData(MetaData_ClientIP, clientIP(req))
+	duplicateRequest, _ := m.GetDuplicateRequest()
+	if duplicateRequest != nil && duplicateRequest.Body != nil {
+		body, err := io.ReadAll(duplicateRequest.Body)
+		if err != nil {
+			return err
+		}
+		m.SetRaw(body)
+	}
+	return nil
+}
+
+var (
+	trustedProxiesMutex sync.RWMutex
+	trustedProxies      []*net.IPNet
+)
+
+// SetTrustedProxies 设置可信代理(IP或CIDR)，只有直连地址为可信代理时才读取 X-Forwarded-For、X-Real-Ip，默认不信任任何代理
+func SetTrustedProxies(proxies ...string) (err error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return errors.Errorf("invalid trusted proxy:%s", proxy)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid trusted proxy:%s", proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+	trustedProxiesMutex.Lock()
+	defer trustedProxiesMutex.Unlock()
+	trustedProxies = nets
+	return nil
+}
+
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return false
+	}
+	trustedProxiesMutex.RLock()
+	defer trustedProxiesMutex.RUnlock()
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP 获取客户端IP，直连地址为可信代理时从 X-Forwarded-For 右往左取第一个非可信代理地址，其次取 X-Real-Ip，否则使用直连地址
+func clientIP(req *http.Request) string {
+	remoteIP, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remoteIP = req.RemoteAddr
+	}
+	if !isTrustedProxy(remoteIP) {
+		return remoteIP
+	}
+	forwardedFor := strings.Split(req.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwardedFor[i])
+		if net.ParseIP(ip) == nil {
+			break
+		}
+		if !isTrustedProxy(ip) {
+			return ip
+		}
+	}
+	realIp := strings.TrimSpace(req.Header.Get("X-Real-Ip"))
+	if net.ParseIP(realIp) != nil {
+		return realIp
+	}
+	return remoteIP
+}
+
 func (m *RequestMessage) String() string {
 	b, err := json.Marshal(m)
 	if err != nil {
@@ -415,6 +553,18 @@ func (m *Message[T]) Run() (err error) {
 	return err
 }
 
+// runWith 以last作为调用链末端执行一次已注册的中间件，执行后恢复原调用链，可重复调用(流式场景每个事件执行一次)
+func (m *Message[T]) runWith(last HandlerFunc[T]) (err error) {
+	origin := m.middlewareFuncs
+	defer func() {
+		m.middlewareFuncs = origin
+	}()
+	m.middlewareFuncs = append(MiddlewareFuncs[T]{}, origin...)
+	m.middlewareFuncs.Add(last)
+	err = m.Run()
+	return err
+}
+
 type MiddlewareFuncs[T any] []HandlerFunc[T]
 
 type MiddlewareFuncsRequestMessage = MiddlewareFuncs[RequestMessage]
@@ -435,23 +585,24 @@ func (ms *MiddlewareFuncs[T]) Add(fns ...HandlerFunc[T]) *MiddlewareFuncs[T] {
 }
 
 func RequestMiddleLog(message *RequestMessage) (err error) {
-	err = message.Next() //读取数据后
-	if err != nil {
-		return err
-	}
-	duplicateReq, ok := message.GetDuplicateRequest()
-	if !ok {
+	return RequestMiddleLogSnippet(SnippetFormat)(message)
+}
+
+// RequestMiddleLogSnippet 请求日志，使用指定格式(SnippetFormat_Xxx)输出重放代码
+func RequestMiddleLogSnippet(format string) HandlerFunc[RequestMessage] {
+	return func(message *RequestMessage) (err error) {
+		err = message.Next() //读取数据后
+		if err != nil {
+			return err
+		}
+		if _, ok := message.GetDuplicateRequest(); !ok {
+			return nil
+		}
+		requestId := message.GetRequestId()
+		msg := fmt.Sprintf("request: requestId:%s;%s: %s", requestId, format, message.Snippet(format))
+		message.GetLog().Info(msg)
 		return nil
 	}
-	curlCommand, err1 := http2curl.GetCurlCommand(duplicateReq)
-	if err1 != nil {
-		message.GetLog().Error("http2curl.GetCurlCommand", err1)
-	}
-	requestId := message.GetRequestId()
-	msg := fmt.Sprintf("request: requestId:%s;curlCommand: %s", requestId, curlCommand.String())
-	message.GetLog().Info(msg)
-
-	return nil
 }
 
 var ResponseBodyLogMaxLen = 512 // 响应体最大长度，超过则截断
@@ -459,10 +610,11 @@ var ResponseBodyLogMaxLen = 512 // 响应体最大长度，超过则截断
 func ResponseMiddleLog(message *ResponseMessage) (err error) {
 	err = message.Next() //读取数据后
 	if err != nil {
+		message.GetLog().Error(fmt.Sprintf("requestId:%s,response error:%s%s", message.GetRequestId(), err.Error(), endpointAttemptsLog(message)))
 		return err
 	}
 	if message.ResponseError != nil {
-		message.GetLog().Error(fmt.Sprintf("requestId:%s,response error:%s", message.GetRequestId(), message.ResponseError.Error()))
+		message.GetLog().Error(fmt.Sprintf("requestId:%s,response error:%s%s", message.GetRequestId(), message.ResponseError.Error(), endpointAttemptsLog(message)))
 		return nil
 	}
 
@@ -479,12 +631,25 @@ func ResponseMiddleLog(message *ResponseMessage) (err error) {
 	if len(body) > ResponseBodyLogMaxLen {
 		body = body[:ResponseBodyLogMaxLen]
 	}
-	msg := fmt.Sprintf("requestId:%s,url:%s,response httpCode: %d;body:%s", message.GetRequestId(), req.URL.String(), duplicateRsp.StatusCode, string(body))
+	msg := fmt.Sprintf("requestId:%s,url:%s,response httpCode: %d;body:%s%s", message.GetRequestId(), req.URL.String(), duplicateRsp.StatusCode, string(body), endpointAttemptsLog(message))
 	message.GetLog().Info(msg)
 
 	return nil
 }
 
+// endpointAttemptsLog 多地址容错的请求记录，无记录时返回空
+func endpointAttemptsLog(message *ResponseMessage) string {
+	v, ok := message.MetaData.Get(MetaData_EndpointAttempts)
+	if !ok {
+		return ""
+	}
+	attempts, ok := v.([]EndpointAttempt)
+	if !ok || len(attempts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(";endpoints:%s", formatEndpointAttempts(attempts))
+}
+
 func RequestMiddleSetLog(log LogI) HandlerFunc[RequestMessage] {
 	return func(message *RequestMessage) (err error) {
 		message.SetLog(log)
@@ -0,0 +1,532 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// RouteEnvelope 接口响应(及请求)的外层协议
+type RouteEnvelope string
+
+const (
+	RouteEnvelope_CodeMessage RouteEnvelope = "code_message" // {code,message,data}，ResponseMiddleCodeMessageForServer 输出
+	RouteEnvelope_OneLayer    RouteEnvelope = "one_layer"    // 标准一层协议 {_errCode,_errStr,_ret,_data}
+	RouteEnvelope_TwoLayer    RouteEnvelope = "two_layer"    // 标准二层协议 请求{_head,_param} 响应{_head,_data:{_ret,_errCode,_errStr,_data}}
+	RouteEnvelope_None        RouteEnvelope = "none"         // 不包装
+)
+
+// RouteDoc 接口文档信息
+type RouteDoc struct {
+	OperationId string
+	Summary     string
+	Description string
+	Tags        []string
+	Envelope    RouteEnvelope // 为空时根据 protoFn 的响应中间件推断，包装或闭包的信封中间件需指定
+}
+
+var (
+	envelopeMiddlewareMutex sync.RWMutex
+	envelopeMiddlewares     = map[uintptr]RouteEnvelope{}
+)
+
+// RegisterEnvelopeMiddleware 登记服务端信封中间件对应的协议，RegisterGinHander 据此推断接口协议；闭包按函数代码登记，同一工厂函数返回的中间件均匹配
+func RegisterEnvelopeMiddleware(envelope RouteEnvelope, fn HandlerFuncResponseMessage) {
+	envelopeMiddlewareMutex.Lock()
+	defer envelopeMiddlewareMutex.Unlock()
+	envelopeMiddlewares[reflect.ValueOf(fn).Pointer()] = envelope
+}
+
+func init() {
+	RegisterEnvelopeMiddleware(RouteEnvelope_CodeMessage, ResponseMiddleCodeMessageForServer)
+	RegisterEnvelopeMiddleware(RouteEnvelope_OneLayer, ResponseMiddleOneLayerForServer)
+	RegisterEnvelopeMiddleware(RouteEnvelope_TwoLayer, ResponseMiddleTwoLayerForServer)
+}
+
+// ProtocolEnvelope 根据响应中间件确定协议，未安装已登记的信封中间件时为 RouteEnvelope_None
+func ProtocolEnvelope(proto *ServerProtocol) RouteEnvelope {
+	envelopeMiddlewareMutex.RLock()
+	defer envelopeMiddlewareMutex.RUnlock()
+	for _, fn := range proto.Response().middlewareFuncs {
+		if envelope, ok := envelopeMiddlewares[reflect.ValueOf(fn).Pointer()]; ok {
+			return envelope
+		}
+	}
+	return RouteEnvelope_None
+}
+
+// routeEnvelope 确定接口协议：未声明时以 protoFn 安装的中间件为准；声明时以声明为准(包装或闭包中间件可能无法识别)，与识别结果不一致时记录日志
+func routeEnvelope(protoFn func() *ServerProtocol, declared RouteEnvelope) RouteEnvelope {
+	proto := protoFn()
+	envelope := ProtocolEnvelope(proto)
+	if declared == "" {
+		return envelope
+	}
+	if declared != envelope {
+		proto.Response().GetLog().Warn(fmt.Sprintf("route envelope %s not match protocol envelope %s,use declared envelope,custom envelope middleware should be registered by RegisterEnvelopeMiddleware", declared, envelope))
+	}
+	return declared
+}
+
+// Route 已注册的接口
+type Route struct {
+	Method string
+	Path   string // gin 格式路径，如 /order/:id
+	Input  reflect.Type
+	Output reflect.Type // 无返回数据时为 nil
+	Doc    RouteDoc
+}
+
+// RouteRegistry 记录通过 RegisterGinHander 注册的接口，用于生成 OpenAPI 文档
+type RouteRegistry struct {
+	mutex  sync.Mutex
+	routes []Route
+}
+
+func NewRouteRegistry() *RouteRegistry {
+	return &RouteRegistry{}
+}
+
+var DefaultRouteRegistry = NewRouteRegistry()
+
+func (r *RouteRegistry) Add(route Route) {
+	if route.Doc.Envelope == "" {
+		route.Doc.Envelope = RouteEnvelope_None
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.routes = append(r.routes, route)
+}
+
+func (r *RouteRegistry) Routes() []Route {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Route(nil), r.routes...)
+}
+
+// RegisterGinHander 注册 gin 路由并记录输入输出类型，registry 为 nil 时使用 DefaultRouteRegistry
+func RegisterGinHander[I any, O any](registry *RouteRegistry, router gin.IRoutes, method string, path string, protoFn func() *ServerProtocol, handler func(in I) (out O, err error), doc RouteDoc) {
+	if registry == nil {
+		registry = DefaultRouteRegistry
+	}
+	doc.Envelope = routeEnvelope(protoFn, doc.Envelope)
+	router.Handle(method, path, NewGinHander(protoFn, handler))
+	registry.Add(Route{
+		Method: method,
+		Path:   path,
+		Input:  reflect.TypeOf((*I)(nil)).Elem(),
+		Output: reflect.TypeOf((*O)(nil)).Elem(),
+		Doc:    doc,
+	})
+}
+
+// RegisterGinHanderCommand 注册无返回数据的 gin 路由
+func RegisterGinHanderCommand[I any](registry *RouteRegistry, router gin.IRoutes, method string, path string, protoFn func() *ServerProtocol, handler func(in I) (err error), doc RouteDoc) {
+	if registry == nil {
+		registry = DefaultRouteRegistry
+	}
+	doc.Envelope = routeEnvelope(protoFn, doc.Envelope)
+	router.Handle(method, path, NewGinHanderCommand(protoFn, handler))
+	registry.Add(Route{
+		Method: method,
+		Path:   path,
+		Input:  reflect.TypeOf((*I)(nil)).Elem(),
+		Doc:    doc,
+	})
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Description          string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title" yaml:"title"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url" yaml:"url"`
+}
+
+type OpenAPIParameter struct {
+	Name     string  `json:"name" yaml:"name"`
+	In       string  `json:"in" yaml:"in"`
+	Required bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *Schema `json:"schema" yaml:"schema"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema" yaml:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content" yaml:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description" yaml:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationId string                     `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                     `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses" yaml:"responses"`
+	Envelope    RouteEnvelope              `json:"x-envelope,omitempty" yaml:"x-envelope,omitempty"` // 扩展字段，供 SDK 生成器选择协议中间件
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+}
+
+// OpenAPIDocument OpenAPI 3 文档
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi" yaml:"openapi"`
+	Info       OpenAPIInfo                             `json:"info" yaml:"info"`
+	Servers    []OpenAPIServer                         `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths" yaml:"paths"`
+	Components OpenAPIComponents                       `json:"components" yaml:"components"`
+}
+
+func (doc OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (doc OpenAPIDocument) YAML() ([]byte, error) {
+	return yaml.Marshal(doc)
+}
+
+// WriteFile 按扩展名(.yaml/.yml/.json)写入文件
+func (doc OpenAPIDocument) WriteFile(filename string) (err error) {
+	var b []byte
+	if strings.HasSuffix(filename, ".yaml") || strings.HasSuffix(filename, ".yml") {
+		b, err = doc.YAML()
+	} else {
+		b, err = doc.JSON()
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
+
+// schemaGenerator 根据Go类型生成Schema，命名结构体放入 components
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	unsafeNameChar = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+	typeArgPkgPath = regexp.MustCompile(`[\w./-]*\.`) // 类型参数中的包路径
+)
+
+// schemaName 去掉泛型类型参数中的包路径(如 Page[github.com/x/pkg.Item] => Page_Item)，同名不同包时加包名区分
+func (g *schemaGenerator) schemaName(t reflect.Type) string {
+	name := strings.Trim(unsafeNameChar.ReplaceAllString(typeArgPkgPath.ReplaceAllString(t.Name(), ""), "_"), "_")
+	if existing, ok := g.types[name]; ok && existing != t {
+		name = unsafeNameChar.ReplaceAllString(path.Base(t.PkgPath()), "_") + "_" + name
+	}
+	return name
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := g.schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			placeholder := &Schema{} // 先占位，支持递归引用
+			g.schemas[name], g.types[name] = placeholder, t
+			*placeholder = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{} // interface 等任意类型
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && indirectType(field.Type).Kind() == reflect.Struct { // 匿名嵌入结构体字段展开
+			embedded := g.structSchema(indirectType(field.Type))
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldSchema := g.schema(field.Type)
+		if strings.Contains(options, "string") {
+			fieldSchema = &Schema{Type: "string"}
+		}
+		if description := field.Tag.Get("description"); description != "" {
+			if fieldSchema.Ref != "" { // $ref 不能与其它属性并存
+				fieldSchema = &Schema{Ref: fieldSchema.Ref}
+			} else {
+				fieldSchema.Description = description
+			}
+		}
+		s.Properties[name] = fieldSchema
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// twoLayerHeadSchema 二层协议 _head
+func twoLayerHeadSchema(msgType string) *Schema {
+	properties := map[string]*Schema{}
+	for _, name := range []string{"_version", "_msgType", "_timestamps", "_invokeId", "_callerServiceId", "_groupNo", "_interface", "_remark"} {
+		properties[name] = &Schema{Type: "string"}
+	}
+	properties["_msgType"].Description = msgType
+	return &Schema{Type: "object", Properties: properties}
+}
+
+func codeMessageSchema(code string, message string, dataName string, data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			code:     {Type: "string"},
+			message:  {Type: "string"},
+			dataName: data,
+		},
+		Required: []string{code, message},
+	}
+}
+
+// requestSchema 按协议包装请求体
+func requestSchema(envelope RouteEnvelope, input *Schema) *Schema {
+	if envelope != RouteEnvelope_TwoLayer {
+		return input
+	}
+	return &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"_head": twoLayerHeadSchema("request"), "_param": input},
+		Required:   []string{"_head", "_param"},
+	}
+}
+
+// responseSchema 按协议包装响应体
+func responseSchema(envelope RouteEnvelope, output *Schema) *Schema {
+	switch envelope {
+	case RouteEnvelope_None:
+		return output
+	case RouteEnvelope_OneLayer:
+		s := codeMessageSchema("_errCode", "_errStr", "_data", output)
+		s.Properties["_ret"] = &Schema{Type: "string"}
+		return s
+	case RouteEnvelope_TwoLayer:
+		data := codeMessageSchema("_errCode", "_errStr", "_data", output)
+		data.Properties["_ret"] = &Schema{Type: "string"}
+		return &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{"_head": twoLayerHeadSchema("response"), "_data": data},
+			Required:   []string{"_head", "_data"},
+		}
+	default:
+		s := codeMessageSchema("code", "message", "data", output)
+		s.Properties["details"] = &Schema{Description: "错误详情"}
+		return s
+	}
+}
+
+// errorResponseSchema 业务错误(4xx/5xx 及 http 200 的业务码)的响应体，无信封时错误响应无内容返回 nil
+func errorResponseSchema(envelope RouteEnvelope) *Schema {
+	if envelope == RouteEnvelope_None {
+		return nil
+	}
+	return responseSchema(envelope, &Schema{Nullable: true})
+}
+
+var ginPathParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// OpenAPI 生成 OpenAPI 3 文档，GET、DELETE、HEAD 请求的输入字段作为查询参数
+func (r *RouteRegistry) OpenAPI(info OpenAPIInfo, servers ...string) OpenAPIDocument {
+	g := &schemaGenerator{schemas: map[string]*Schema{}, types: map[string]reflect.Type{}}
+	doc := OpenAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       info,
+		Paths:      map[string]map[string]*OpenAPIOperation{},
+		Components: OpenAPIComponents{Schemas: g.schemas},
+	}
+	for _, server := range servers {
+		doc.Servers = append(doc.Servers, OpenAPIServer{URL: server})
+	}
+	for _, route := range r.Routes() {
+		path := ginPathParam.ReplaceAllString(route.Path, "{$1}")
+		operation := &OpenAPIOperation{
+			OperationId: route.Doc.OperationId,
+			Summary:     route.Doc.Summary,
+			Description: route.Doc.Description,
+			Tags:        route.Doc.Tags,
+			Responses:   map[string]OpenAPIResponse{},
+			Envelope:    route.Doc.Envelope,
+		}
+		if operation.OperationId == "" {
+			operation.OperationId = strings.ToLower(route.Method) + "_" + strings.Trim(unsafeNameChar.ReplaceAllString(strings.ReplaceAll(path, "/", "_"), ""), "_.")
+		}
+		for _, match := range ginPathParam.FindAllStringSubmatch(route.Path, -1) {
+			operation.Parameters = append(operation.Parameters, OpenAPIParameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		switch strings.ToUpper(route.Method) {
+		case http.MethodGet, http.MethodDelete, http.MethodHead:
+			operation.Parameters = append(operation.Parameters, queryParameters(g, route.Input)...)
+		default:
+			operation.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content:  map[string]OpenAPIMediaType{ContentTypeJson: {Schema: requestSchema(route.Doc.Envelope, g.schema(route.Input))}},
+			}
+		}
+		var output *Schema
+		if route.Output != nil {
+			output = g.schema(route.Output)
+		} else {
+			output = &Schema{Nullable: true}
+		}
+		operation.Responses["200"] = OpenAPIResponse{
+			Description: "success",
+			Content:     map[string]OpenAPIMediaType{ContentTypeJson: {Schema: responseSchema(route.Doc.Envelope, output)}},
+		}
+		if errSchema := errorResponseSchema(route.Doc.Envelope); errSchema != nil {
+			operation.Responses["default"] = OpenAPIResponse{
+				Description: "error",
+				Content:     map[string]OpenAPIMediaType{ContentTypeJson: {Schema: errSchema}},
+			}
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*OpenAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+	return doc
+}
+
+// queryParameters 结构体顶层字段转换为查询参数
+func queryParameters(g *schemaGenerator, input reflect.Type) (parameters []OpenAPIParameter) {
+	if input == nil || indirectType(input).Kind() != reflect.Struct {
+		return nil
+	}
+	s := g.structSchema(indirectType(input))
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	for _, name := range names {
+		parameters = append(parameters, OpenAPIParameter{Name: name, In: "query", Required: required[name], Schema: s.Properties[name]})
+	}
+	return parameters
+}
+
+// OpenAPIHandler 输出 OpenAPI 文档，请求路径以 .yaml/.yml 结尾或 format=yaml 时输出 yaml，否则输出 json
+func (r *RouteRegistry) OpenAPIHandler(info OpenAPIInfo, servers ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		doc := r.OpenAPI(info, servers...)
+		asYaml := strings.HasSuffix(req.URL.Path, ".yaml") || strings.HasSuffix(req.URL.Path, ".yml") || req.URL.Query().Get("format") == "yaml"
+		var b []byte
+		var err error
+		contentType := ContentTypeJson
+		if asYaml {
+			b, err = doc.YAML()
+			contentType = "application/yaml"
+		} else {
+			b, err = doc.JSON()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(b)
+	})
+}
@@ -0,0 +1,140 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type openapiOrder struct {
+	Id       string         `json:"id" description:"订单ID"`
+	Amount   float64        `json:"amount"`
+	Items    []openapiItem  `json:"items,omitempty"`
+	Parent   *openapiOrder  `json:"parent,omitempty"`
+	Extra    map[string]any `json:"extra,omitempty"`
+	internal string
+}
+
+type openapiItem struct {
+	Sku   string `json:"sku"`
+	Count int    `json:"count"`
+}
+
+type openapiQuery struct {
+	PageIndex int    `json:"pageIndex"`
+	Keyword   string `json:"keyword,omitempty"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	registry := NewRouteRegistry()
+	RegisterGinHander(registry, engine, http.MethodPost, "/order", envelopeProtoFn(RouteEnvelope_CodeMessage), func(in openapiOrder) (out openapiOrder, err error) {
+		return in, nil
+	}, RouteDoc{Summary: "创建订单", Tags: []string{"order"}})
+	RegisterGinHander(registry, engine, http.MethodGet, "/order/:id", envelopeProtoFn(RouteEnvelope_TwoLayer), func(in openapiQuery) (out []openapiOrder, err error) {
+		return []openapiOrder{{Id: "1"}}, nil
+	}, RouteDoc{})
+	RegisterGinHanderCommand(registry, engine, http.MethodDelete, "/order/:id", envelopeProtoFn(RouteEnvelope_OneLayer), func(in map[string]any) (err error) {
+		return nil
+	}, RouteDoc{Envelope: RouteEnvelope_OneLayer})
+	engine.GET("/openapi.json", gin.WrapH(registry.OpenAPIHandler(OpenAPIInfo{Title: "order", Version: "1.0"})))
+	engine.GET("/openapi.yaml", gin.WrapH(registry.OpenAPIHandler(OpenAPIInfo{Title: "order", Version: "1.0"})))
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	doc := registry.OpenAPI(OpenAPIInfo{Title: "order", Version: "1.0"})
+	create := doc.Paths["/order"]["post"]
+	if create == nil || create.Summary != "创建订单" {
+		t.Fatalf("missing create operation %+v", doc.Paths)
+	}
+	data := create.Responses["200"].Content[ContentTypeJson].Schema.Properties["data"]
+	if data.Ref != "#/components/schemas/openapiOrder" {
+		t.Fatalf("unexpected data schema %+v", data)
+	}
+	order := doc.Components.Schemas["openapiOrder"]
+	if order.Properties["id"].Description != "订单ID" || order.Properties["parent"].Ref == "" || strings.Join(order.Required, ",") != "amount,id" {
+		t.Fatalf("unexpected order schema %+v", order)
+	}
+	errResponse := create.Responses["default"].Content[ContentTypeJson].Schema
+	if errResponse == nil || errResponse.Properties["code"] == nil || errResponse.Properties["details"] == nil {
+		t.Fatalf("missing error response %+v", create.Responses)
+	}
+	if create.Envelope != RouteEnvelope_CodeMessage {
+		t.Fatalf("envelope should be derived from protocol,got %s", create.Envelope)
+	}
+	if count := doc.Components.Schemas["openapiItem"].Properties["count"]; count.Format != "int64" {
+		t.Fatalf("int should be int64,got %+v", count)
+	}
+	get := doc.Paths["/order/{id}"]["get"]
+	if len(get.Parameters) != 3 || get.Parameters[0].In != "path" {
+		t.Fatalf("unexpected parameters %+v", get.Parameters)
+	}
+	twoLayer := get.Responses["200"].Content[ContentTypeJson].Schema
+	if twoLayer.Properties["_head"] == nil || twoLayer.Properties["_data"].Properties["_data"].Type != "array" {
+		t.Fatalf("unexpected two layer schema %+v", twoLayer)
+	}
+	oneLayer := doc.Paths["/order/{id}"]["delete"].Responses["200"].Content[ContentTypeJson].Schema
+	if oneLayer.Properties["_errCode"] == nil || oneLayer.Properties["_ret"] == nil {
+		t.Fatalf("unexpected one layer schema %+v", oneLayer)
+	}
+
+	client := NewClientProtocol(http.MethodGet, server.URL+"/order/1")
+	client.SetLog(LogIgnore{})
+	client.Request().AddMiddleware(RequestMiddleTwoLayerForClient(TwoLayerHead{CallerServiceId: "110001"}))
+	client.Response().AddMiddleware(ResponseMiddleTwoLayerForClientOf[[]openapiOrder])
+	var orders []openapiOrder
+	if err := client.Do(nil, &orders); err != nil || len(orders) != 1 || orders[0].Id != "1" {
+		t.Fatalf("two layer server response:%v,%+v,%s", err, orders, client.Response().GetRaw())
+	}
+
+	response, err := http.Get(server.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	var served map[string]any
+	if err = json.Unmarshal(b, &served); err != nil || served["openapi"] != "3.0.3" {
+		t.Fatalf("unexpected json document %s", b)
+	}
+	response, err = http.Get(server.URL + "/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = io.ReadAll(response.Body)
+	response.Body.Close()
+	if !strings.HasPrefix(string(b), "openapi: 3.0.3") {
+		t.Fatalf("unexpected yaml document %s", b)
+	}
+}
+
+func TestRouteEnvelopeMismatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := NewRouteRegistry()
+	RegisterGinHander(registry, gin.New(), http.MethodGet, "/raw", envelopeProtoFn(RouteEnvelope_None), func(in map[string]any) (out map[string]any, err error) {
+		return in, nil
+	}, RouteDoc{})
+	if envelope := registry.Routes()[0].Doc.Envelope; envelope != RouteEnvelope_None {
+		t.Fatalf("protocol without envelope middleware,got %s", envelope)
+	}
+	wrapped := func() *ServerProtocol { // 包装后的信封中间件无法按函数识别，以声明为准
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(func(message *ResponseMessage) (err error) {
+			return ResponseMiddleCodeMessageForServer(message)
+		})
+		p.SetLog(LogIgnore{})
+		return p
+	}
+	RegisterGinHander(registry, gin.New(), http.MethodGet, "/order", wrapped, func(in map[string]any) (out map[string]any, err error) {
+		return in, nil
+	}, RouteDoc{Envelope: RouteEnvelope_CodeMessage})
+	if envelope := registry.Routes()[1].Doc.Envelope; envelope != RouteEnvelope_CodeMessage {
+		t.Fatalf("declared envelope should be used,got %s", envelope)
+	}
+}
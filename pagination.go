@@ -0,0 +1,207 @@
+package apihttpprotocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var (
+	DefaultPageSize = 10   // pageSize 未传或小于1时使用
+	MaxPageSize     = 1000 // pageSize 上限，防止一次查询过多数据
+)
+
+// PageNumber 分页数值，兼容字符串("10")和数字(10)输入，输出为数字
+type PageNumber int
+
+func (n *PageNumber) UnmarshalJSON(b []byte) (err error) {
+	b = bytes.Trim(b, `"`)
+	if len(b) == 0 || string(b) == "null" {
+		*n = 0
+		return nil
+	}
+	i, err := strconv.Atoi(string(b))
+	if err != nil {
+		return errors.WithMessagef(err, "invalid page number:%s", string(b))
+	}
+	*n = PageNumber(i)
+	return nil
+}
+
+func (n PageNumber) String() string {
+	return strconv.Itoa(int(n))
+}
+
+// PageInput 分页参数，pageIndex 从0开始，可嵌入到列表接口的输入结构体中
+type PageInput struct {
+	PageSize  PageNumber `json:"pageSize"`
+	PageIndex PageNumber `json:"pageIndex"`
+}
+
+func (p PageInput) GetPageInput() PageInput {
+	return p
+}
+
+// Normalize 修正非法值，pageSize 取默认值或上限，pageIndex 最小为0
+func (p PageInput) Normalize() PageInput {
+	if p.PageSize < 1 {
+		p.PageSize = PageNumber(DefaultPageSize)
+	}
+	if MaxPageSize > 0 && int(p.PageSize) > MaxPageSize {
+		p.PageSize = PageNumber(MaxPageSize)
+	}
+	if p.PageIndex < 0 {
+		p.PageIndex = 0
+	}
+	return p
+}
+
+func (p PageInput) Limit() int {
+	return int(p.Normalize().PageSize)
+}
+
+func (p PageInput) Offset() int {
+	p = p.Normalize()
+	return int(p.PageIndex) * int(p.PageSize)
+}
+
+// PageInputI 包含分页参数的输入，嵌入 PageInput 即实现
+type PageInputI interface {
+	GetPageInput() PageInput
+}
+
+// PageOutput 分页输出
+type PageOutput[T any] struct {
+	Items     []T   `json:"items"`
+	Total     int64 `json:"total"`
+	PageIndex int   `json:"pageIndex"`
+	PageSize  int   `json:"pageSize"`
+	PageCount int   `json:"pageCount"`
+}
+
+func NewPageOutput[T any](page PageInput, items []T, total int64) PageOutput[T] {
+	page = page.Normalize()
+	if items == nil {
+		items = make([]T, 0) // 输出 [] 而不是 null
+	}
+	pageSize := int64(page.PageSize)
+	return PageOutput[T]{
+		Items:     items,
+		Total:     total,
+		PageIndex: int(page.PageIndex),
+		PageSize:  int(page.PageSize),
+		PageCount: int((total + pageSize - 1) / pageSize),
+	}
+}
+
+// HasMore 是否还有下一页，总数未知(为0)时按本页是否取满判断
+func (p PageOutput[T]) HasMore() bool {
+	if p.Total > 0 {
+		return int64(p.PageIndex+1)*int64(p.PageSize) < p.Total
+	}
+	return p.PageSize > 0 && len(p.Items) >= p.PageSize
+}
+
+// NewGinPageHander 列表接口，handler 按 in 中修正后的分页参数查询，返回当前页数据及总数
+func NewGinPageHander[I PageInputI, T any](protoFn func() *ServerProtocol, handler func(in I, page PageInput) (items []T, total int64, err error)) func(c *gin.Context) {
+	return NewGinHander(protoFn, func(in I) (out PageOutput[T], err error) {
+		page := in.GetPageInput().Normalize()
+		items, total, err := handler(in, page)
+		if err != nil {
+			return out, err
+		}
+		return NewPageOutput(page, items, total), nil
+	})
+}
+
+// PageIterator 客户端逐页遍历列表接口，调用 Next 时才请求下一页
+type PageIterator[T any] struct {
+	pageSize int
+	request  func(page PageInput) (client *ClientProtocol, in any)
+	page     PageOutput[T]
+	pageRaw  []byte // 当前页数据，用于识别服务端忽略 pageIndex 返回的重复页
+	started  bool
+	index    int
+	err      error
+}
+
+// NewPageIterator request 按分页参数创建客户端(每页新建，需添加对应信封中间件)及请求参数
+func NewPageIterator[T any](pageSize int, request func(page PageInput) (client *ClientProtocol, in any)) *PageIterator[T] {
+	return &PageIterator[T]{
+		pageSize: pageSize,
+		request:  request,
+		index:    -1,
+	}
+}
+
+func (it *PageIterator[T]) fetch(pageIndex int) (err error) {
+	page := PageInput{PageSize: PageNumber(it.pageSize), PageIndex: PageNumber(pageIndex)}.Normalize()
+	client, in := it.request(page)
+	out := PageOutput[T]{}
+	err = client.Do(in, &out)
+	if err != nil {
+		return errors.WithMessagef(err, "pageIndex:%d", pageIndex)
+	}
+	out.PageIndex = int(page.PageIndex) // 以请求页为准，避免服务端忽略 pageIndex 时重复请求同一页
+	if out.PageSize == 0 {
+		out.PageSize = int(page.PageSize) // 服务端未返回分页信息时使用请求参数
+	}
+	raw, err := json.Marshal(out.Items)
+	if err != nil {
+		return err
+	}
+	if pageIndex > 0 && bytes.Equal(raw, it.pageRaw) { // 与上一页相同，服务端未按 pageIndex 分页，结束遍历避免死循环
+		out.Items = nil
+	}
+	it.page = out
+	it.pageRaw = raw
+	it.index = -1
+	return nil
+}
+
+// Next 移动到下一条数据，当前页读完时请求下一页，没有数据或出错时返回false
+func (it *PageIterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		if it.err = it.fetch(0); it.err != nil {
+			return false
+		}
+	}
+	for it.index+1 >= len(it.page.Items) {
+		if len(it.page.Items) == 0 || !it.page.HasMore() {
+			return false
+		}
+		if it.err = it.fetch(it.page.PageIndex + 1); it.err != nil {
+			return false
+		}
+	}
+	it.index++
+	return true
+}
+
+func (it *PageIterator[T]) Item() T {
+	return it.page.Items[it.index]
+}
+
+// Page 当前页
+func (it *PageIterator[T]) Page() PageOutput[T] {
+	return it.page
+}
+
+func (it *PageIterator[T]) Err() error {
+	return it.err
+}
+
+// All 读取剩余全部数据
+func (it *PageIterator[T]) All() (items []T, err error) {
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
@@ -0,0 +1,152 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type pageOrderQuery struct {
+	PageInput
+	Keyword string `json:"keyword"`
+}
+
+type pageOrder struct {
+	Id string `json:"id"`
+}
+
+func TestPageInput(t *testing.T) {
+	for _, body := range []string{`{"pageSize":"20","pageIndex":"2"}`, `{"pageSize":20,"pageIndex":2}`} {
+		in := pageOrderQuery{}
+		if err := json.Unmarshal([]byte(body), &in); err != nil {
+			t.Fatal(err)
+		}
+		if in.Limit() != 20 || in.Offset() != 40 {
+			t.Fatalf("%s: limit:%d,offset:%d", body, in.Limit(), in.Offset())
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"pageSize":"a"}`), &PageInput{}); err == nil {
+		t.Fatal("expected invalid page number error")
+	}
+	page := PageInput{PageSize: 5000, PageIndex: -1}.Normalize()
+	if int(page.PageSize) != MaxPageSize || page.PageIndex != 0 {
+		t.Fatalf("normalize:%+v", page)
+	}
+	if (PageInput{}).Limit() != DefaultPageSize {
+		t.Fatal("default page size")
+	}
+}
+
+func TestPagination(t *testing.T) {
+	orders := make([]pageOrder, 0)
+	for i := 0; i < 25; i++ {
+		orders = append(orders, pageOrder{Id: fmt.Sprintf("%d", i)})
+	}
+	requests := 0
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	protoFn := func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		p.SetLog(LogIgnore{})
+		return p
+	}
+	handler := NewGinPageHander(protoFn, func(in pageOrderQuery, page PageInput) (items []pageOrder, total int64, err error) {
+		requests++
+		if in.Keyword != "all" {
+			return nil, 0, nil
+		}
+		start, end := page.Offset(), page.Offset()+page.Limit()
+		if start > len(orders) {
+			start = len(orders)
+		}
+		if end > len(orders) {
+			end = len(orders)
+		}
+		return orders[start:end], int64(len(orders)), nil
+	})
+	engine.GET("/orders", handler)
+	engine.POST("/orders", handler)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	rsp, err := http.Post(server.URL+"/orders", ContentTypeJson, strings.NewReader(`{"pageSize":"10","pageIndex":"2","keyword":"all"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	envelope := ResponseOf[PageOutput[pageOrder]]{}
+	if err = json.NewDecoder(rsp.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	page := envelope.Data
+	if len(page.Items) != 5 || page.Total != 25 || page.PageIndex != 2 || page.PageSize != 10 || page.PageCount != 3 || page.HasMore() {
+		t.Fatalf("page:%+v", page)
+	}
+
+	requests = 0
+	it := NewPageIterator[pageOrder](10, func(page PageInput) (client *ClientProtocol, in any) {
+		query, _ := FormCodec{}.Marshal(pageOrderQuery{PageInput: page, Keyword: "all"})
+		client = NewClientProtocol(http.MethodGet, server.URL+"/orders?"+string(query))
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(ResponseMiddleCodeMessageForClientOf[PageOutput[pageOrder]])
+		return client, nil
+	})
+	if !it.Next() || it.Item().Id != "0" || requests != 1 { // 只请求第一页
+		t.Fatalf("first item,requests:%d,err:%v", requests, it.Err())
+	}
+	items, err := it.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 24 || items[23].Id != "24" || requests != 3 {
+		t.Fatalf("items:%d,requests:%d", len(items), requests)
+	}
+
+	it = NewPageIterator[pageOrder](10, func(page PageInput) (client *ClientProtocol, in any) {
+		client = NewClientProtocol(http.MethodPost, server.URL+"/orders")
+		client.SetLog(LogIgnore{})
+		client.SetHeader("Content-Type", ContentTypeJson)
+		client.Response().AddMiddleware(ResponseMiddleCodeMessageForClientOf[PageOutput[pageOrder]])
+		return client, pageOrderQuery{PageInput: page, Keyword: "none"}
+	})
+	if it.Next() || it.Err() != nil {
+		t.Fatalf("expected empty,err:%v", it.Err())
+	}
+}
+
+func TestPageIteratorIgnoredPageIndex(t *testing.T) {
+	requests := 0
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/orders", NewGinHander(func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		p.SetLog(LogIgnore{})
+		return p
+	}, func(in map[string]any) (out PageOutput[pageOrder], err error) {
+		requests++
+		return PageOutput[pageOrder]{Items: []pageOrder{{Id: "0"}, {Id: "1"}}}, nil // 忽略分页参数且不返回总数
+	}))
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	it := NewPageIterator[pageOrder](2, func(page PageInput) (client *ClientProtocol, in any) {
+		query, _ := FormCodec{}.Marshal(page)
+		client = NewClientProtocol(http.MethodGet, server.URL+"/orders?"+string(query))
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(ResponseMiddleCodeMessageForClientOf[PageOutput[pageOrder]])
+		return client, nil
+	})
+	items, err := it.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || requests != 2 {
+		t.Fatalf("items:%d,requests:%d", len(items), requests)
+	}
+}
@@ -0,0 +1,61 @@
+package apihttpprotocol
+
+import (
+	"reflect"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ProtoJSONMarshalOptions   = protojson.MarshalOptions{}
+	ProtoJSONUnmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true} // 兼容服务端新增字段
+)
+
+// ProtoJSON 使用 protojson 编解码 proto.Message，用于嵌入json信封(如 Response.Data)，保留proto的json字段名及枚举名称
+type ProtoJSON struct {
+	Message proto.Message
+}
+
+func (p ProtoJSON) MarshalJSON() ([]byte, error) {
+	return ProtoJSONMarshalOptions.Marshal(p.Message)
+}
+
+func (p *ProtoJSON) UnmarshalJSON(b []byte) error {
+	return ProtoJSONUnmarshalOptions.Unmarshal(b, p.Message)
+}
+
+// WrapProtoJSON 如果v是proto.Message(或指向proto.Message指针的指针)，包装为 ProtoJSON，否则原样返回
+func WrapProtoJSON(v any) any {
+	msg, ok := protoMessageOf(v)
+	if !ok {
+		return v
+	}
+	return &ProtoJSON{Message: msg}
+}
+
+// protoMessageOf 提取proto.Message，兼容 **pb.Xxx 形式(如 NewGinHander[*pb.Xxx] 中的 &in)，为nil时自动分配
+func protoMessageOf(v any) (msg proto.Message, ok bool) {
+	if v == nil {
+		return nil, false
+	}
+	msg, ok = v.(proto.Message)
+	if ok {
+		return msg, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Pointer {
+		return nil, false
+	}
+	elem := rv.Elem()
+	if !elem.Type().Implements(protoMessageType) {
+		return nil, false
+	}
+	if elem.IsNil() {
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	msg = elem.Interface().(proto.Message)
+	return msg, true
+}
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
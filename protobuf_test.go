@@ -0,0 +1,70 @@
+package apihttpprotocol
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+func newProtobufTestServer(withEnvelope bool) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	protoFn := func() *ServerProtocol {
+		p := NewServerProtocol()
+		if withEnvelope {
+			p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		}
+		p.SetLog(LogIgnore{})
+		return p
+	}
+	engine.POST("/field", NewGinHander(protoFn, func(in *typepb.Field) (out *typepb.Field, err error) {
+		in.Number = 2
+		return in, nil
+	}))
+	return httptest.NewServer(engine)
+}
+
+func TestProtobufEnvelope(t *testing.T) {
+	server := newProtobufTestServer(true)
+	defer server.Close()
+	client := NewClientProtocol("POST", server.URL+"/field")
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeJson)
+	client.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+	var out *typepb.Field
+	err := client.Do(&typepb.Field{Kind: typepb.Field_TYPE_STRING, TypeUrl: "order"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := string(client.Response().GetRaw())
+	if !strings.Contains(raw, `"kind":"TYPE_STRING"`) || !strings.Contains(raw, `"typeUrl":"order"`) {
+		t.Fatalf("unexpected body %s", raw)
+	}
+	if out.Kind != typepb.Field_TYPE_STRING || out.TypeUrl != "order" || out.Number != 2 {
+		t.Fatalf("unexpected %v", out)
+	}
+}
+
+func TestProtobufTransport(t *testing.T) {
+	server := newProtobufTestServer(false)
+	defer server.Close()
+	client := NewClientProtocol("POST", server.URL+"/field")
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeProtobuf)
+	client.SetHeader("Accept", ContentTypeProtobuf)
+	var out *typepb.Field
+	err := client.Do(&typepb.Field{Kind: typepb.Field_TYPE_BOOL, Name: "enabled"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicateRsp, _ := client.Response().GetDuplicateResponse()
+	if contentType := duplicateRsp.Header.Get("Content-Type"); contentType != ContentTypeProtobuf {
+		t.Fatalf("unexpected content type %s", contentType)
+	}
+	if out.Kind != typepb.Field_TYPE_BOOL || out.Name != "enabled" || out.Number != 2 {
+		t.Fatalf("unexpected %v", out)
+	}
+}
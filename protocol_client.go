@@ -5,20 +5,18 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"reflect"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
-	"github.com/spf13/cast"
 	"resty.dev/v3"
 )
 
 type ClientProtocol struct {
 	_Protocol
+	httpClient *http.Client
 }
 
 func _NewClientProtocol() *ClientProtocol {
@@ -105,12 +103,39 @@ var newRestyClient = sync.OnceValue(func() (client *resty.Client) {
 
 func newClient(timeout time.Duration) (client *http.Client) {
 	client = &http.Client{
-		Transport: sharedTransport,
+		Transport: defaultTransport,
 		Timeout:   timeout,
 	}
 	return client
 }
 
+// DoFunc 执行http请求，与 http.Client.Do 签名一致
+type DoFunc func(req *http.Request) (*http.Response, error)
+
+// WrapDo 包装客户端网络请求，在响应中间件调用 Next 前注册，用于重试、缓存等需要控制网络调用的场景，先注册的在外层
+func (m *ResponseMessage) WrapDo(wrapper func(next DoFunc) DoFunc) *ResponseMessage {
+	m.doWrappers = append(m.doWrappers, wrapper)
+	return m
+}
+
+// WrapDoInner 包装最内层的网络请求，在所有 WrapDo 之后执行，可得到服务发现、多地址容错改写后的最终请求，用于签名等依赖最终地址的场景
+func (m *ResponseMessage) WrapDoInner(wrapper func(next DoFunc) DoFunc) *ResponseMessage {
+	m.innerDoWrappers = append(m.innerDoWrappers, wrapper)
+	return m
+}
+
+// buildDo 组装网络请求函数，组装后清空已注册的包装，避免重复执行时叠加
+func (m *ResponseMessage) buildDo(do DoFunc) DoFunc {
+	for i := len(m.innerDoWrappers) - 1; i >= 0; i-- {
+		do = m.innerDoWrappers[i](do)
+	}
+	for i := len(m.doWrappers) - 1; i >= 0; i-- {
+		do = m.doWrappers[i](do)
+	}
+	m.doWrappers, m.innerDoWrappers = nil, nil
+	return do
+}
+
 const (
 	MetaData_CurlCmd = "curl_cmd"
 )
@@ -118,6 +143,7 @@ const (
 type ResponseError struct {
 	HttpCode    int
 	CurlCommand string
+	Snippet     string // SnippetFormat 格式的重放代码，格式为 curl 时为空
 	Body        string
 }
 
@@ -126,8 +152,16 @@ func (re ResponseError) Error() string {
 	return s
 }
 
+// responseErrorSnippet curl 已记录在 CurlCommand 中，其它格式记录在 Snippet 中
+func responseErrorSnippet(requestMessage *RequestMessage) string {
+	if SnippetFormat == SnippetFormat_Curl {
+		return ""
+	}
+	return requestMessage.Snippet(SnippetFormat)
+}
+
 func NewClientProtocol(method string, url string) *ClientProtocol {
-	client := newClient(10 * time.Second)
+	var clientProtocol *ClientProtocol
 	var req *http.Request
 	readFn := func(message *ResponseMessage) (err error) {
 		requestMessage, ok := message.GetRequestMessage()
@@ -135,7 +169,7 @@ func NewClientProtocol(method string, url string) *ClientProtocol {
 			err = errors.Errorf("requestMessage is nil")
 			return err
 		}
-		response, err := client.Do(req)
+		response, err := message.buildDo(clientProtocol.httpClient.Do)(req)
 		if err != nil {
 			return err
 		}
@@ -159,32 +193,39 @@ func NewClientProtocol(method string, url string) *ClientProtocol {
 			responseError := ResponseError{
 				HttpCode:    httpCode,
 				CurlCommand: requestMessage.CurlCommand(),
+				Snippet:     responseErrorSnippet(requestMessage),
 				Body:        string(body),
 			}
 			return responseError
 		}
 
-		if message.GoStructRef != nil {
-			if len(body) > 0 {
-				if json.Valid(body) { // 暂时只解析json格式的响应体
-					err = json.Unmarshal(body, message.GoStructRef)
-					if err != nil {
-						responseError := ResponseError{
-							HttpCode:    httpCode,
-							CurlCommand: requestMessage.CurlCommand(),
-							Body:        fmt.Sprintf("response body json.Unmarshal  err:%s,body:%s", err.Error(), string(body)),
-						}
-						return responseError
-					}
-				} else {
+		if message.GoStructRef != nil && len(body) > 0 {
+			codec, ok := GetCodec(response.Header.Get("Content-Type"))
+			if !ok {
+				if !json.Valid(body) { // 未识别的Content-Type,兼容按json解析
 					responseError := ResponseError{
 						HttpCode:    httpCode,
 						CurlCommand: requestMessage.CurlCommand(),
+						Snippet:     responseErrorSnippet(requestMessage),
 						Body:        fmt.Sprintf("response body is not valid json,body:%s", string(body)),
 					}
 					return responseError
 				}
-
+				codec = DefaultCodec()
+			}
+			err = codec.Unmarshal(body, message.GoStructRef)
+			var codeErr ErrorWithCode
+			if errors.As(err, &codeErr) { // 解码过程中产生的业务错误(如解密失败)直接返回
+				return err
+			}
+			if err != nil {
+				responseError := ResponseError{
+					HttpCode:    httpCode,
+					CurlCommand: requestMessage.CurlCommand(),
+					Snippet:     responseErrorSnippet(requestMessage),
+					Body:        fmt.Sprintf("response body %s Unmarshal err:%s,body:%s", codec.ContentType(), err.Error(), string(body)),
+				}
+				return responseError
 			}
 		}
 		err = message.Next()
@@ -205,7 +246,8 @@ func NewClientProtocol(method string, url string) *ClientProtocol {
 		}
 		return nil
 	}
-	clientProtocol := _NewClientProtocol().WithIOFn(readFn, writeFn)
+	clientProtocol = _NewClientProtocol().WithIOFn(readFn, writeFn)
+	clientProtocol.httpClient = newClient(10 * time.Second)
 	clientProtocol.Request().URL = url
 	clientProtocol.Request().Method = method
 	return clientProtocol
@@ -219,27 +261,12 @@ func RequestMiddleEncodeBody(message *RequestMessage) (err error) {
 	switch rt.Kind() {
 	case reflect.Struct:
 		contentType := message.Headers.Get("Content-Type")
-		if strings.Contains(contentType, "application/x-www-form-urlencoded") { // 表单提交
-			values := url.Values{}
-			m := map[string]any{}
-			decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-				ZeroFields: true,
-				Result:     &m,
-				TagName:    "json",
-			})
-			if err != nil {
-				return err
-			}
-			err = decoder.Decode(message.GoStructRef)
+		if strings.Contains(contentType, ContentTypeForm) { // 表单提交
+			b, err := FormCodec{}.Marshal(message.GoStructRef)
 			if err != nil {
 				return err
 			}
-
-			for k, v := range m {
-				values.Add(k, cast.ToString(v))
-			}
-
-			message.GoStructRef = values.Encode()
+			message.GoStructRef = string(b)
 		}
 	}
 	err = message.Next()
@@ -249,22 +276,55 @@ func RequestMiddleEncodeBody(message *RequestMessage) (err error) {
 	return nil
 }
 
+// ResponseMiddleCodeMessageForClient 客户端解析 code/message/data 响应，Do 的响应参数可为任意类型，委托 ResponseMiddleCodeMessageForClientOf 处理
 func ResponseMiddleCodeMessageForClient(message *ResponseMessage) (err error) {
-	response := &Response{
-		Data: message.GoStructRef,
+	message.GoStructRef = anyResponseTarget(message)
+	return ResponseMiddleCodeMessageForClientOf[any](message)
+}
+
+// ResponseMiddleCodeMessageForClientOf 按 ResponseOf[T] 解码，Do 的响应参数需为 *T 或 nil
+func ResponseMiddleCodeMessageForClientOf[T any](message *ResponseMessage) (err error) {
+	out, err := responseTargetOf[T](message)
+	if err != nil {
+		return err
+	}
+	response := &ResponseOf[T]{}
+	if out != nil {
+		response.Data = *out
 	}
 	message.GoStructRef = response
 	err = message.Next()
 	if err != nil {
-		return err
+		return remoteErrorFromResponseError(message, err)
 	}
 	err = response.Validate()
 	if err != nil {
-		return err
+		return newRemoteError(message, response.Code, response.Message, response.Details, response.Data)
+	}
+	if out != nil {
+		*out = response.Data
 	}
 	return nil
 }
 
+// anyResponseTarget 非类型化中间件将 Do 传入的响应参数包装为 *any，json 解码时写入原参数
+func anyResponseTarget(message *ResponseMessage) *any {
+	data := WrapProtoJSON(message.GoStructRef)
+	return &data
+}
+
+// responseTargetOf 取出 Do 传入的响应参数
+func responseTargetOf[T any](message *ResponseMessage) (out *T, err error) {
+	if message.GoStructRef == nil {
+		return nil, nil
+	}
+	out, ok := message.GoStructRef.(*T)
+	if !ok {
+		return nil, errors.Errorf("response GoStructRef type %T not match %s", message.GoStructRef, reflect.TypeOf(out).String())
+	}
+	return out, nil
+}
+
 // httpRequestToResty 将已有的 *http.Request 转换成 *resty.Request
 // func httpRequestToResty(client *resty.Client, req *http.Request) (*resty.Request, error) {
 // 	r := client.R()
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -14,6 +13,9 @@ import (
 
 type ServerProtocol struct {
 	_Protocol
+	streamWriter       HandlerFuncResponseMessage
+	httpRequest        *http.Request
+	httpResponseWriter http.ResponseWriter
 }
 
 func NewServerProtocol() *ServerProtocol {
@@ -30,6 +32,12 @@ func (p *ServerProtocol) WithIOFn(reder HandlerFuncRequestMessage, writer Handle
 	return p
 }
 
+// WithHttpRequest 设置原始http请求，ReadRequest 执行中间件前会用它填充请求消息
+func (p *ServerProtocol) WithHttpRequest(req *http.Request) *ServerProtocol {
+	p.httpRequest = req
+	return p
+}
+
 func (p *ServerProtocol) ResponseSuccess(data any) {
 	err := p.writeResponse(data)
 	if err != nil {
@@ -40,6 +48,12 @@ func (p *ServerProtocol) ResponseSuccess(data any) {
 func (p *ServerProtocol) ReadRequest(dst any) (err error) {
 	request := p.Request()
 	request.GoStructRef = dst
+	if p.httpRequest != nil {
+		err = request.SetHttpRequest(p.httpRequest)
+		if err != nil {
+			return err
+		}
+	}
 	request.middlewareFuncs.Add(request.GetIOReader())
 	err = request.Run()
 	if err != nil {
@@ -62,9 +76,17 @@ func (p *ServerProtocol) writeResponse(data any) (err error) {
 func (p *ServerProtocol) ResponseFail(err error) {
 	response := p.Response()
 	response.ResponseError = err
+	httpStatus := getHttpStatus(err)
+	if httpStatus == 0 { // 错误未指定时使用注册的状态码
+		definition, _ := DefaultErrorCodeRegistry.Get(getBusinessCode(err))
+		httpStatus = definition.HttpStatus
+	}
+	if httpStatus > 0 {
+		response.HttpCode = httpStatus
+	}
 	err = p.writeResponse(nil)
 	if err != nil {
-		panic(err) // 业务本身报错，在写入时还报错，直接panic ，避免循环调用
+		p.writePlain(err) // 业务本身报错，在写入时还报错，使用纯文本兜底输出，避免循环调用
 	}
 }
 
@@ -104,27 +126,23 @@ func readInput(req *http.Request, dst any) (err error) {
 	if err != nil {
 		return err
 	}
-	m := map[string]string{}
-
-	for k, v := range req.Form {
-		m[k] = v[0] // 先简单处理，获取第一个，后续再优化处理数组情况
+	formDst := dst
+	if wrapper, ok := dst.(goStructRefWrapper); ok { // 如解密包装，url、表单参数解码到原始目标
+		formDst = wrapper.unwrapGoStructRef()
 	}
-	if len(m) > 0 {
-		b, err := json.Marshal(m)
-		if err != nil {
-			return err
-		}
-		err = json.Unmarshal(b, dst)
-		if err != nil {
-			return err
-
-		}
+	err = decodeFormValues(req.Form, formDst) // url 参数、表单参数
+	if err != nil {
+		return err
 	}
 
 	if len(body) > 0 {
-		contentType := req.Header.Get("Content-Type") // 这里为了支持 ContentTypeForceJson ,所以先读取
-		if strings.Contains(contentType, ContentTypeJson) || ContentTypeForceJson {
-			err = json.Unmarshal(body, &dst) // 如果url上有和body参数同名的，会使用body的参数覆盖url上的同名参数
+		contentType := req.Header.Get("Content-Type")
+		codec, ok := GetCodec(contentType)
+		if !ok && ContentTypeForceJson { // 未识别的Content-Type 按json处理
+			codec, ok = DefaultCodec(), true
+		}
+		if ok && codec.ContentType() != ContentTypeForm { // 表单已在 ParseForm 中处理
+			err = codec.Unmarshal(body, dst) // 如果url上有和body参数同名的，会使用body的参数覆盖url上的同名参数
 			if err != nil {
 				return err
 			}
@@ -134,28 +152,45 @@ func readInput(req *http.Request, dst any) (err error) {
 	return nil
 }
 
+// negotiateResponseCodec 确定响应编解码器，优先级: Accept > 请求Content-Type > 默认(json)
+func negotiateResponseCodec(req *http.Request) (codec Codec) {
+	codec, ok := GetCodecByAccept(req.Header.Get("Accept"))
+	if ok {
+		return codec
+	}
+	codec, ok = GetCodec(req.Header.Get("Content-Type"))
+	if ok && codec.ContentType() != ContentTypeForm {
+		return codec
+	}
+	return DefaultCodec()
+}
+
 func NewGinReadWriteMiddleware(c *gin.Context) (readFn HandlerFuncRequestMessage, writeFn HandlerFuncResponseMessage) {
-	var contentType string
 	readFn = func(message *RequestMessage) (err error) {
-		err = message.SetDuplicateRequest(c.Request)
-		if err != nil {
-			return err
+		if message.duplicateRequest == nil { // 未通过 WithHttpRequest 预先填充
+			err = message.SetHttpRequest(c.Request)
+			if err != nil {
+				return err
+			}
 		}
 		req := c.Request
 		err = readInput(req, message.GoStructRef)
 		if err != nil {
-			return nil
-		}
-
-		for k, v := range c.Request.Header {
-			message.SetHeader(k, v[0])
+			var codeErr ErrorWithCode
+			if errors.As(err, &codeErr) { // 业务错误(如解密失败)保留原业务码
+				return err
+			}
+			return WrapCodeError(err, Business_Code_Invalid_Params, BusinessMessage_Invalid_Params).WithHttpStatus(http.StatusBadRequest)
 		}
-
-		return err
+		return nil
 	}
 	writeFn = func(message *ResponseMessage) (err error) {
+		httpCode := message.HttpCode
+		if httpCode == 0 {
+			httpCode = http.StatusOK
+		}
 		duplicateResponse := &http.Response{
-			StatusCode: http.StatusOK,
+			StatusCode: httpCode,
 			Header:     http.Header{},
 		}
 		if message.requestMessage != nil {
@@ -164,19 +199,23 @@ func NewGinReadWriteMiddleware(c *gin.Context) (readFn HandlerFuncRequestMessage
 		requestId := message.GetRequestId()
 		c.Header("X-Request-Id", requestId)
 		duplicateResponse.Header.Add("X-Request-Id", requestId)
+		codec := negotiateResponseCodec(c.Request)
 		var b []byte
 		if message.GoStructRef != nil {
-			if strings.Contains(contentType, ContentTypeJson) || ContentTypeForceJson {
-				b, err = json.Marshal(message.GoStructRef)
-				if err != nil {
-					return err
-				}
+			b, err = codec.Marshal(message.GoStructRef)
+			if errors.Is(err, ErrCodecNotSupport) { // 数据不支持协商的格式时，使用默认格式输出
+				codec = DefaultCodec()
+				b, err = codec.Marshal(message.GoStructRef)
+			}
+			if err != nil {
+				return err
 			}
 		}
 
 		body := string(b)
 
-		c.String(http.StatusOK, body)
+		c.Data(httpCode, codec.ContentType(), b)
+		duplicateResponse.Header.Set("Content-Type", codec.ContentType())
 
 		if body != "" {
 			duplicateResponse.Body = io.NopCloser(bytes.NewReader([]byte(body)))
@@ -191,7 +230,8 @@ func NewGinReadWriteMiddleware(c *gin.Context) (readFn HandlerFuncRequestMessage
 func NewGinHander[I any, O any](protoFn func() *ServerProtocol, handler func(in I) (out O, err error)) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		proto := protoFn() //每次请求需要重新创建协议对象，防止并发安全问题
-		proto.WithIOFn(NewGinReadWriteMiddleware(c))
+		proto.WithIOFn(NewGinReadWriteMiddleware(c)).WithHttpRequest(c.Request).WithHttpResponseWriter(c.Writer)
+		defer proto.Recover()
 		var in I
 		err := proto.ReadRequest(&in)
 		if err != nil {
@@ -210,7 +250,8 @@ func NewGinHander[I any, O any](protoFn func() *ServerProtocol, handler func(in
 func NewGinHanderCommand[I any](protoFn func() *ServerProtocol, handler func(in I) (err error)) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		proto := protoFn() //每次请求需要重新创建协议对象，防止并发安全问题
-		proto.WithIOFn(NewGinReadWriteMiddleware(c))
+		proto.WithIOFn(NewGinReadWriteMiddleware(c)).WithHttpRequest(c.Request).WithHttpResponseWriter(c.Writer)
+		defer proto.Recover()
 		var in I
 		err := proto.ReadRequest(&in)
 		if err != nil {
@@ -227,17 +268,24 @@ func NewGinHanderCommand[I any](protoFn func() *ServerProtocol, handler func(in
 }
 
 var (
-	Business_Code_Success = "0"
-	Business_Code_Fail    = "1"
+	Business_Code_Success        = "0"
+	Business_Code_Fail           = "1"
+	Business_Code_Invalid_Params = "400000" // 请求体、url参数解析失败
+
+	BusinessMessage_Invalid_Params = "invalid params"
 )
 
-type Response struct {
+// ResponseOf code/message 信封，Data 为具体类型，解码时无需依赖 any 中保存的指针
+type ResponseOf[T any] struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
-	Data    any    `json:"data"`
+	Details any    `json:"details,omitempty"`
+	Data    T      `json:"data"`
 }
 
-func (rsp *Response) Validate() (err error) {
+type Response = ResponseOf[any]
+
+func (rsp *ResponseOf[T]) Validate() (err error) {
 	if rsp.Code != Business_Code_Success {
 		if rsp.Message == "" {
 			rsp.Message = fmt.Sprintf("%v", rsp.Data)
@@ -248,6 +296,43 @@ func (rsp *Response) Validate() (err error) {
 	return nil
 }
 
+func (rsp ResponseOf[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Details any    `json:"details,omitempty"`
+		Data    any    `json:"data"`
+	}{
+		Code:    rsp.Code,
+		Message: rsp.Message,
+		Details: rsp.Details,
+		Data:    WrapProtoJSON(rsp.Data),
+	})
+}
+
+func (rsp *ResponseOf[T]) UnmarshalJSON(b []byte) (err error) {
+	raw := struct {
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Details any             `json:"details"`
+		Data    json.RawMessage `json:"data"`
+	}{}
+	err = json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+	rsp.Code, rsp.Message, rsp.Details = raw.Code, raw.Message, raw.Details
+	return unmarshalEnvelopeData(raw.Data, &rsp.Data)
+}
+
+// unmarshalEnvelopeData 解码信封中的数据部分，proto 消息使用 protojson，null 保持原值
+func unmarshalEnvelopeData(data json.RawMessage, dst any) (err error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, WrapProtoJSON(dst))
+}
+
 var (
 // BusinessCode = "businessCode"
 )
@@ -256,7 +341,8 @@ func ResponseMiddleCodeMessageForServer(message *ResponseMessage) error {
 	response := &Response{
 		Code:    message.GetBusinessCode(),
 		Message: message.GetBusinessMessage(),
-		Data:    message.GoStructRef,
+		Details: message.GetBusinessDetails(),
+		Data:    WrapProtoJSON(message.GoStructRef),
 	}
 	message.GoStructRef = response
 	err := message.Next()
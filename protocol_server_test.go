@@ -0,0 +1,50 @@
+package apihttpprotocol
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testProtoFn 测试用服务端协议，忽略日志，setup 安装测试所需中间件
+func testProtoFn(setup func(p *ServerProtocol)) func() *ServerProtocol {
+	return func() *ServerProtocol {
+		p := NewServerProtocol()
+		if setup != nil {
+			setup(p)
+		}
+		p.SetLog(LogIgnore{})
+		return p
+	}
+}
+
+// envelopeProtoFn 按协议安装服务端信封中间件
+func envelopeProtoFn(envelope RouteEnvelope) func() *ServerProtocol {
+	return testProtoFn(func(p *ServerProtocol) {
+		switch envelope {
+		case RouteEnvelope_CodeMessage:
+			p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		case RouteEnvelope_OneLayer:
+			p.Response().AddMiddleware(ResponseMiddleOneLayerForServer)
+		case RouteEnvelope_TwoLayer:
+			p.Request().AddMiddleware(RequestMiddleTwoLayerForServer)
+			p.Response().AddMiddleware(ResponseMiddleTwoLayerForServer)
+		}
+	})
+}
+
+// newTestEngine 测试模式的 gin 路由
+func newTestEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return gin.New()
+}
+
+// newTestServer 注册路由并启动测试服务，测试结束时关闭
+func newTestServer(t *testing.T, register func(engine *gin.Engine)) *httptest.Server {
+	engine := newTestEngine()
+	register(engine)
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,295 @@
+// Package protocoltest 提供 apihttpprotocol 客户端测试工具：声明式的 mock 传输层及录制/回放
+package protocoltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/suifengpiao14/apihttpprotocol"
+)
+
+var ErrNoExpectation = errors.New("protocoltest: no expectation matched")
+
+// Envelope 将响应数据包装为响应体
+type Envelope func(data any) (body []byte, contentType string, err error)
+
+// EnvelopeCodeMessage 成功的 code/message/data 包装，与 ResponseMiddleCodeMessageForServer 输出一致
+func EnvelopeCodeMessage(data any) (body []byte, contentType string, err error) {
+	return EnvelopeFail(apihttpprotocol.Business_Code_Success, "success")(data)
+}
+
+// EnvelopeFail 失败的 code/message/data 包装
+func EnvelopeFail(code string, message string) Envelope {
+	return func(data any) (body []byte, contentType string, err error) {
+		response := apihttpprotocol.Response{
+			Code:    code,
+			Message: message,
+			Data:    apihttpprotocol.WrapProtoJSON(data),
+		}
+		body, err = apihttpprotocol.DefaultCodec().Marshal(response)
+		return body, apihttpprotocol.ContentTypeJson, err
+	}
+}
+
+// EnvelopeRaw 不包装，按 contentType 对应的编解码器(json/xml/protobuf/form)直接编码数据
+func EnvelopeRaw(contentType string) Envelope {
+	return func(data any) (body []byte, outContentType string, err error) {
+		codec, ok := apihttpprotocol.GetCodec(contentType)
+		if !ok {
+			return nil, "", errors.WithMessagef(apihttpprotocol.ErrCodecNotSupport, "content-type:%s", contentType)
+		}
+		switch v := data.(type) {
+		case []byte:
+			return v, codec.ContentType(), nil
+		case string:
+			return []byte(v), codec.ContentType(), nil
+		}
+		body, err = codec.Marshal(data)
+		return body, codec.ContentType(), err
+	}
+}
+
+// BodyMatcher 请求体匹配
+type BodyMatcher func(body []byte) bool
+
+// BodyJSONEq 请求体与 expected 序列化后的json语义相等
+func BodyJSONEq(expected any) BodyMatcher {
+	return func(body []byte) bool {
+		b, err := json.Marshal(expected)
+		if err != nil {
+			return false
+		}
+		var want, got any
+		if json.Unmarshal(b, &want) != nil || json.Unmarshal(body, &got) != nil {
+			return false
+		}
+		return reflect.DeepEqual(want, got)
+	}
+}
+
+// BodyContains 请求体包含 substr
+func BodyContains(substr string) BodyMatcher {
+	return func(body []byte) bool {
+		return bytes.Contains(body, []byte(substr))
+	}
+}
+
+// Expectation 一条期望：匹配条件及对应的响应
+type Expectation struct {
+	method   string
+	rawUrl   string
+	headers  map[string]string
+	matchers []BodyMatcher
+
+	status      int
+	header      http.Header
+	data        any
+	envelope    Envelope
+	err         error
+	times       int // 0 表示不限次数
+	calledTimes int
+}
+
+// WithBody 增加请求体匹配条件
+func (e *Expectation) WithBody(matcher BodyMatcher) *Expectation {
+	e.matchers = append(e.matchers, matcher)
+	return e
+}
+
+// WithHeader 增加请求头匹配条件
+func (e *Expectation) WithHeader(key string, value string) *Expectation {
+	e.headers[key] = value
+	return e
+}
+
+// Reply 设置响应，envelope 为 nil 时使用 EnvelopeCodeMessage
+func (e *Expectation) Reply(status int, data any, envelope Envelope) *Expectation {
+	if envelope == nil {
+		envelope = EnvelopeCodeMessage
+	}
+	e.status, e.data, e.envelope = status, data, envelope
+	return e
+}
+
+// ReplySuccess 以 code/message/data 包装返回200
+func (e *Expectation) ReplySuccess(data any) *Expectation {
+	return e.Reply(http.StatusOK, data, EnvelopeCodeMessage)
+}
+
+// ReplyHeader 设置响应头
+func (e *Expectation) ReplyHeader(key string, value string) *Expectation {
+	e.header.Set(key, value)
+	return e
+}
+
+// ReplyError 模拟网络错误
+func (e *Expectation) ReplyError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Times 限制匹配次数，超过后不再匹配
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// match rawUrl 以 / 开头时只匹配路径及查询参数
+func (e *Expectation) match(req *http.Request, body []byte) bool {
+	if e.times > 0 && e.calledTimes >= e.times {
+		return false
+	}
+	if e.method != "" && !strings.EqualFold(e.method, req.Method) {
+		return false
+	}
+	if e.rawUrl != "" {
+		target := req.URL.String()
+		if strings.HasPrefix(e.rawUrl, "/") {
+			target = req.URL.RequestURI()
+		}
+		if !sameUrl(e.rawUrl, target) {
+			return false
+		}
+	}
+	for key, value := range e.headers {
+		if req.Header.Get(key) != value {
+			return false
+		}
+	}
+	for _, matcher := range e.matchers {
+		if !matcher(body) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameUrl 忽略查询参数顺序
+func sameUrl(a string, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	if ua.Scheme != ub.Scheme || ua.Host != ub.Host || ua.Path != ub.Path {
+		return false
+	}
+	return ua.Query().Encode() == ub.Query().Encode()
+}
+
+func (e *Expectation) String() string {
+	return fmt.Sprintf("%s %s", e.method, e.rawUrl)
+}
+
+// MockTransport 声明式的 mock 传输层，通过 ClientProtocol.SetTransport 或 apihttpprotocol.SetDefaultTransport 注入
+type MockTransport struct {
+	Fallback     http.RoundTripper // 未匹配时使用，为空时返回 ErrNoExpectation
+	mutex        sync.Mutex
+	expectations []*Expectation
+	requests     []*http.Request
+}
+
+func NewMockTransport() *MockTransport {
+	return &MockTransport{}
+}
+
+// On 注册期望，method 为空匹配任意方法，rawUrl 为空匹配任意地址
+func (m *MockTransport) On(method string, rawUrl string) *Expectation {
+	e := &Expectation{
+		method:   method,
+		rawUrl:   rawUrl,
+		headers:  map[string]string{},
+		header:   http.Header{},
+		status:   http.StatusOK,
+		envelope: EnvelopeCodeMessage,
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	m.mutex.Lock()
+	recorded := req.Clone(req.Context())
+	recorded.Body = io.NopCloser(bytes.NewReader(body))
+	m.requests = append(m.requests, recorded)
+	var matched *Expectation
+	for _, e := range m.expectations {
+		if e.match(req, body) {
+			matched = e
+			e.calledTimes++
+			break
+		}
+	}
+	m.mutex.Unlock()
+	if matched == nil {
+		if m.Fallback != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			return m.Fallback.RoundTrip(req)
+		}
+		return nil, errors.WithMessagef(ErrNoExpectation, "%s %s", req.Method, req.URL.String())
+	}
+	if matched.err != nil {
+		return nil, matched.err
+	}
+	responseBody, contentType, err := matched.envelope(matched.data)
+	if err != nil {
+		return nil, err
+	}
+	header := matched.header.Clone()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", matched.status, http.StatusText(matched.status)),
+		StatusCode:    matched.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(responseBody)),
+		ContentLength: int64(len(responseBody)),
+		Request:       req,
+	}, nil
+}
+
+// Requests 已收到的请求(body可重复读取)
+func (m *MockTransport) Requests() []*http.Request {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]*http.Request(nil), m.requests...)
+}
+
+// AssertExpectations 检查设置了 Times 的期望是否满足，未设置 Times 的期望至少被调用一次
+func (m *MockTransport) AssertExpectations(t testing.TB) {
+	t.Helper()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, e := range m.expectations {
+		switch {
+		case e.times > 0 && e.calledTimes != e.times:
+			t.Errorf("expectation %s: expected %d calls,got %d", e, e.times, e.calledTimes)
+		case e.times == 0 && e.calledTimes == 0:
+			t.Errorf("expectation %s: never called", e)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package protocoltest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/suifengpiao14/apihttpprotocol"
+)
+
+func newClient(method string, url string, transport http.RoundTripper) *apihttpprotocol.ClientProtocol {
+	client := apihttpprotocol.NewClientProtocol(method, url)
+	client.SetLog(apihttpprotocol.LogIgnore{})
+	if transport != nil {
+		client.SetTransport(transport)
+	}
+	return client
+}
+
+func TestMockTransport(t *testing.T) {
+	mock := NewMockTransport()
+	mock.On("POST", "http://order.local/api/order/create").
+		WithBody(BodyJSONEq(map[string]any{"name": "book"})).
+		ReplySuccess(map[string]any{"id": 1}).
+		Times(1)
+	mock.On("GET", "/api/order/get?id=2").Reply(http.StatusOK, nil, EnvelopeFail("404000", "order not found"))
+
+	client := newClient("POST", "http://order.local/api/order/create", mock)
+	client.SetHeader("Content-Type", apihttpprotocol.ContentTypeJson)
+	client.Response().AddMiddleware(apihttpprotocol.ResponseMiddleCodeMessageForClient)
+	var out map[string]any
+	err := client.Do(map[string]any{"name": "book"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["id"] != float64(1) {
+		t.Fatalf("unexpected out %v", out)
+	}
+
+	client = newClient("GET", "http://order.local/api/order/get?id=2", mock)
+	client.Response().AddMiddleware(apihttpprotocol.ResponseMiddleCodeMessageForClient)
+	if err = client.Do(nil, &out); err == nil {
+		t.Fatal("business failure expected")
+	}
+
+	client = newClient("GET", "http://order.local/unknown", mock)
+	if err = client.Do(nil, nil); !errors.Is(err, ErrNoExpectation) {
+		t.Fatalf("expected ErrNoExpectation,got %v", err)
+	}
+	mock.AssertExpectations(t)
+	if len(mock.Requests()) != 3 {
+		t.Fatalf("expected 3 requests,got %d", len(mock.Requests()))
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", apihttpprotocol.ContentTypeJson)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(`{"code":"0","message":"success","data":"recorded"}`))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	call := func(mode RecordMode) (out string, err error) {
+		client := newClient("GET", server.URL+"/api/config", nil)
+		client.Response().AddMiddleware(NewRecorder(dir, mode).Middleware(), apihttpprotocol.ResponseMiddleCodeMessageForClient)
+		err = client.Do(nil, &out)
+		return out, err
+	}
+	out, err := call(RecordMode_Auto)
+	if err != nil || out != "recorded" {
+		t.Fatalf("out:%s,err:%v", out, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected one golden file,got %d", len(entries))
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, entries[0].Name())); strings.Contains(string(b), "secret") {
+		t.Fatalf("Set-Cookie should not be recorded:%s", b)
+	}
+	server.Close()
+	out, err = call(RecordMode_Replay)
+	if err != nil || out != "recorded" || hits != 1 {
+		t.Fatalf("replay failed,out:%s,err:%v,hits:%d", out, err, hits)
+	}
+}
@@ -0,0 +1,232 @@
+package protocoltest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/suifengpiao14/apihttpprotocol"
+)
+
+var ErrGoldenNotFound = errors.New("protocoltest: golden file not found")
+
+type RecordMode string
+
+const (
+	RecordMode_Record RecordMode = "record" // 访问真实服务并保存
+	RecordMode_Replay RecordMode = "replay" // 只从文件回放，不存在时报错
+	RecordMode_Auto   RecordMode = "auto"   // 文件存在时回放，否则录制
+)
+
+// GoldenBody 请求/响应体，非utf8内容(如protobuf)以base64保存
+type GoldenBody struct {
+	Encoding string `json:"encoding,omitempty"`
+	Content  string `json:"content"`
+}
+
+func newGoldenBody(b []byte) GoldenBody {
+	if utf8.Valid(b) {
+		return GoldenBody{Content: string(b)}
+	}
+	return GoldenBody{Encoding: "base64", Content: base64.StdEncoding.EncodeToString(b)}
+}
+
+func (b GoldenBody) Bytes() ([]byte, error) {
+	if b.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(b.Content)
+	}
+	return []byte(b.Content), nil
+}
+
+type GoldenRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   GoldenBody  `json:"body"`
+}
+
+type GoldenResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       GoldenBody  `json:"body"`
+}
+
+// GoldenExchange 一次请求及响应
+type GoldenExchange struct {
+	Request  GoldenRequest  `json:"request"`
+	Response GoldenResponse `json:"response"`
+}
+
+// Recorder 录制/回放，文件名由方法、路径及 apihttpprotocol.CacheKey 生成
+type Recorder struct {
+	Dir                 string
+	Mode                RecordMode
+	VaryHeaders         []string // 参与匹配的请求头
+	SkipHeaders         []string // 录制时不保存的请求头，默认 Authorization、Cookie
+	SkipResponseHeaders []string // 录制时不保存的响应头，默认 Set-Cookie
+}
+
+func NewRecorder(dir string, mode RecordMode) *Recorder {
+	return &Recorder{
+		Dir:                 dir,
+		Mode:                mode,
+		SkipHeaders:         []string{"Authorization", "Cookie"},
+		SkipResponseHeaders: []string{"Set-Cookie"},
+	}
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Filename 请求对应的文件路径
+func (r *Recorder) Filename(req *http.Request) (filename string, err error) {
+	key, err := apihttpprotocol.CacheKey(req, r.VaryHeaders)
+	if err != nil {
+		return "", err
+	}
+	name := strings.Trim(unsafeFilenameChars.ReplaceAllString(req.URL.Path, "_"), "_")
+	return filepath.Join(r.Dir, fmt.Sprintf("%s_%s_%s.json", strings.ToLower(req.Method), name, key[:12])), nil
+}
+
+// Load 读取录制的响应
+func (r *Recorder) Load(filename string) (exchange *GoldenExchange, err error) {
+	b, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, errors.WithMessagef(ErrGoldenNotFound, "filename:%s", filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	exchange = &GoldenExchange{}
+	err = json.Unmarshal(b, exchange)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "parse golden file:%s", filename)
+	}
+	return exchange, nil
+}
+
+// save 使用 GetDuplicateRequest/GetDuplicateResponse 保存真实请求及响应
+func (r *Recorder) save(filename string, message *apihttpprotocol.ResponseMessage) (err error) {
+	requestMessage, ok := message.GetRequestMessage()
+	if !ok {
+		return nil
+	}
+	req, ok := requestMessage.GetDuplicateRequest()
+	if !ok {
+		return nil
+	}
+	response, ok := message.GetDuplicateResponse()
+	if !ok {
+		return nil
+	}
+	requestBody, err := readAll(req.Body)
+	if err != nil {
+		return err
+	}
+	responseBody, err := readAll(response.Body)
+	if err != nil {
+		return err
+	}
+	header := req.Header.Clone()
+	for _, key := range r.SkipHeaders {
+		header.Del(key)
+	}
+	responseHeader := response.Header.Clone()
+	for _, key := range r.SkipResponseHeaders {
+		responseHeader.Del(key)
+	}
+	exchange := GoldenExchange{
+		Request: GoldenRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: header,
+			Body:   newGoldenBody(requestBody),
+		},
+		Response: GoldenResponse{
+			StatusCode: response.StatusCode,
+			Header:     responseHeader,
+			Body:       newGoldenBody(responseBody),
+		},
+	}
+	b, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
+
+func readAll(body io.ReadCloser) (b []byte, err error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (exchange *GoldenExchange) toResponse(req *http.Request) (response *http.Response, err error) {
+	body, err := exchange.Response.Body.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Response.StatusCode, http.StatusText(exchange.Response.StatusCode)),
+		StatusCode:    exchange.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        exchange.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Middleware 录制/回放响应中间件，需放在其它响应中间件之前
+func (r *Recorder) Middleware() apihttpprotocol.HandlerFunc[apihttpprotocol.ResponseMessage] {
+	return func(message *apihttpprotocol.ResponseMessage) (err error) {
+		filename := ""
+		replayed := false
+		message.WrapDo(func(next apihttpprotocol.DoFunc) apihttpprotocol.DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				name, err := r.Filename(req)
+				if err != nil {
+					return nil, err
+				}
+				filename = name
+				if r.Mode == RecordMode_Record {
+					return next(req)
+				}
+				exchange, err := r.Load(filename)
+				if errors.Is(err, ErrGoldenNotFound) && r.Mode == RecordMode_Auto {
+					return next(req)
+				}
+				if err != nil {
+					return nil, err
+				}
+				replayed = true
+				return exchange.toResponse(req)
+			}
+		})
+		err = message.Next()
+		if filename == "" || replayed {
+			return err
+		}
+		saveErr := r.save(filename, message) // 非200响应同样录制
+		if saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+}
@@ -0,0 +1,161 @@
+package apihttpprotocol
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	Business_Code_Too_Many_Requests = "429000"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter 按key分桶的令牌桶限流器，需全局共享(不要在 protoFn 中创建)
+type RateLimiter struct {
+	rate      float64 // 每秒生成令牌数
+	burst     float64 // 桶容量
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastClean time.Time
+}
+
+func NewRateLimiter(ratePerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    ratePerSecond,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// reserve 预占一个令牌，需等待时间超过 maxWait 时不预占并返回 false
+func (l *RateLimiter) reserve(key string, maxWait time.Duration) (wait time.Duration, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	l.clean(now)
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0, true
+	}
+	if l.rate <= 0 {
+		return 0, false
+	}
+	wait = time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	if wait > maxWait {
+		return wait, false
+	}
+	bucket.tokens--
+	return wait, true
+}
+
+// clean 定期清理已回满的桶，避免key过多占用内存
+func (l *RateLimiter) clean(now time.Time) {
+	if now.Sub(l.lastClean) < time.Minute {
+		return
+	}
+	l.lastClean = now
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Allow 是否允许通过，不等待
+func (l *RateLimiter) Allow(key string) bool {
+	_, ok := l.reserve(key, 0)
+	return ok
+}
+
+// Wait 等待令牌，最多等待 maxWait，超过时返回 ErrRateLimited
+func (l *RateLimiter) Wait(ctx context.Context, key string, maxWait time.Duration) (err error) {
+	wait, ok := l.reserve(key, maxWait)
+	if !ok {
+		return errors.WithMessagef(ErrRateLimited, "key:%s,need wait:%s", key, wait)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimitKeyFunc 限流key，返回空时不限流
+type RateLimitKeyFunc func(message *RequestMessage) (key string)
+
+func RateLimitKeyByClientIP(message *RequestMessage) (key string) {
+	v, _ := message.MetaData.Get(MetaData_ClientIP)
+	key, _ = v.(string)
+	return key
+}
+
+// RateLimitKeyByCallerServiceId 按调用方服务ID限流，优先取请求头 Header_CallerServiceId，其次取二层协议请求体 _head._callerServiceId
+func RateLimitKeyByCallerServiceId(message *RequestMessage) (key string) {
+	return callerServiceId(message)
+}
+
+// RateLimitKeyByPrincipal 按认证主体限流，需放在 RequestMiddleAuth 之后
+func RateLimitKeyByPrincipal(message *RequestMessage) (key string) {
+	principal, ok := message.GetPrincipal()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", principal.Type, principal.Subject)
+}
+
+// RequestMiddleRateLimit 服务端限流，超限返回429及 Business_Code_Too_Many_Requests
+func RequestMiddleRateLimit(limiter *RateLimiter, keyFn RateLimitKeyFunc) HandlerFunc[RequestMessage] {
+	return func(message *RequestMessage) (err error) {
+		key := keyFn(message)
+		if key != "" && !limiter.Allow(key) {
+			return NewCodeError(Business_Code_Too_Many_Requests, "too many requests").WithHttpStatus(http.StatusTooManyRequests)
+		}
+		return message.Next()
+	}
+}
+
+// ResponseMiddleRateLimit 客户端按上游host限流，令牌不足时最多等待 maxWait，maxWait<=0 时直接失败
+func ResponseMiddleRateLimit(limiter *RateLimiter, maxWait time.Duration) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		message.WrapDo(func(next DoFunc) DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				err := limiter.Wait(req.Context(), req.URL.Host, maxWait)
+				if err != nil {
+					return nil, err
+				}
+				return next(req)
+			}
+		})
+		return message.Next()
+	}
+}
@@ -0,0 +1,94 @@
+package apihttpprotocol
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	serverLimiter := NewRateLimiter(0.001, 2)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	protoFn := func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Request().AddMiddleware(RequestMiddleRateLimit(serverLimiter, RateLimitKeyByClientIP))
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		p.SetLog(LogIgnore{})
+		return p
+	}
+	engine.GET("/ping", NewGinHander(protoFn, func(in map[string]any) (out string, err error) {
+		return "pong", nil
+	}))
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		client := NewClientProtocol("GET", server.URL+"/ping")
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+		var out string
+		err := client.Do(nil, &out)
+		if i < 2 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 {
+			var responseError ResponseError
+			if !errors.As(err, &responseError) || responseError.HttpCode != http.StatusTooManyRequests {
+				t.Fatalf("expected 429,got %v", err)
+			}
+		}
+	}
+
+	clientLimiter := NewRateLimiter(100, 1)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		client := NewClientProtocol("GET", server.URL+"/unknown")
+		client.SetLog(LogIgnore{})
+		client.Response().AddMiddleware(ResponseMiddleRateLimit(clientLimiter, time.Second))
+		_ = client.Do(nil, nil)
+	}
+	if time.Since(start) < 5*time.Millisecond {
+		t.Fatal("client limiter should wait for token")
+	}
+	client := NewClientProtocol("GET", server.URL+"/unknown")
+	client.SetLog(LogIgnore{})
+	client.Response().AddMiddleware(ResponseMiddleRateLimit(clientLimiter, 0))
+	err := client.Do(nil, nil)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected fail fast,got %v", err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1")
+	req.Header.Set("X-Real-Ip", "2.2.2.2")
+	if ip := clientIP(req); ip != "203.0.113.9" { // 默认不信任代理头
+		t.Fatalf("untrusted proxy:%s", ip)
+	}
+	if err := SetTrustedProxies("10.0.0.0/8", "192.168.1.1"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies()
+	if ip := clientIP(req); ip != "203.0.113.9" {
+		t.Fatalf("remote not trusted:%s", ip)
+	}
+	req.RemoteAddr = "10.0.0.2:5000"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 8.8.8.8, 192.168.1.1")
+	if ip := clientIP(req); ip != "8.8.8.8" { // 取最右侧非可信代理地址，伪造的最左侧地址被忽略
+		t.Fatalf("trusted proxy:%s", ip)
+	}
+	req.Header.Del("X-Forwarded-For")
+	if ip := clientIP(req); ip != "2.2.2.2" {
+		t.Fatalf("real ip:%s", ip)
+	}
+	if err := SetTrustedProxies("not-ip"); err == nil {
+		t.Fatal("expected invalid proxy error")
+	}
+}
@@ -0,0 +1,86 @@
+package apihttpprotocol
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	Business_Code_Panic = "500000"
+)
+
+var (
+	BusinessMessage_Panic = "internal server error" // panic 时返回给调用方的信息，具体原因只记录在日志中
+)
+
+// WithHttpResponseWriter 设置原始响应写入器，响应写入失败时用于纯文本兜底输出
+func (p *ServerProtocol) WithHttpResponseWriter(w http.ResponseWriter) *ServerProtocol {
+	p.httpResponseWriter = w
+	return p
+}
+
+// Recover 捕获 panic，记录堆栈并按 Business_Code_Panic 输出错误，需通过 defer 调用
+func (p *ServerProtocol) Recover() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	p.recovered(r, debug.Stack())
+}
+
+func (p *ServerProtocol) recovered(r any, stack []byte) {
+	response := p.Response()
+	requestId := response.GetRequestId()
+	response.GetLog().Error(fmt.Sprintf("requestId:%s,panic:%v\n%s", requestId, r, stack))
+	if p.responseWritten() { // 响应已部分输出，无法再写入错误信息
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil { // 输出错误的中间件再次 panic
+			p.writePlain(fmt.Errorf("panic:%v", r))
+		}
+	}()
+	p.ResponseFail(NewCodeError(Business_Code_Panic, BusinessMessage_Panic).WithHttpStatus(http.StatusInternalServerError))
+}
+
+func (p *ServerProtocol) responseWritten() bool {
+	if written, ok := p.httpResponseWriter.(interface{ Written() bool }); ok {
+		return written.Written()
+	}
+	return false
+}
+
+// writePlain 最后兜底输出，不经过中间件和编解码器
+func (p *ServerProtocol) writePlain(cause error) {
+	response := p.Response()
+	requestId := response.GetRequestId()
+	response.GetLog().Error(fmt.Sprintf("requestId:%s,write response fail:%s", requestId, cause.Error()))
+	w := p.httpResponseWriter
+	if w == nil || p.responseWritten() {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Request-Id", requestId)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "%s,requestId:%s", http.StatusText(http.StatusInternalServerError), requestId)
+}
+
+// NewGinRecovery gin 中间件，捕获后续处理函数(非 NewGinHander 创建)的 panic，按协议格式输出错误
+func NewGinRecovery(protoFn func() *ServerProtocol) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			c.Abort()
+			proto := protoFn()
+			proto.WithIOFn(NewGinReadWriteMiddleware(c)).WithHttpRequest(c.Request).WithHttpResponseWriter(c.Writer)
+			proto.recovered(r, debug.Stack())
+		}()
+		c.Next()
+	}
+}
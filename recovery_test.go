@@ -0,0 +1,104 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type recoveryLog struct {
+	LogIgnore
+	errors []string
+}
+
+func (l *recoveryLog) Error(v ...any) {
+	for _, s := range v {
+		l.errors = append(l.errors, s.(string))
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log := &recoveryLog{}
+	protoFn := func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		p.SetLog(log)
+		return p
+	}
+	engine := gin.New()
+	engine.POST("/panic", NewGinHander(protoFn, func(in map[string]any) (out any, err error) {
+		panic("boom")
+	}))
+	engine.POST("/writer-panic", NewGinHander(func() *ServerProtocol {
+		p := protoFn()
+		p.Response().AddMiddleware(func(message *ResponseMessage) error {
+			panic("writer boom")
+		})
+		return p
+	}, func(in map[string]any) (out any, err error) {
+		return "ok", nil
+	}))
+	group := engine.Group("/raw", NewGinRecovery(protoFn))
+	group.GET("/panic", func(c *gin.Context) {
+		panic("raw boom")
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		log.errors = nil
+		req := httptest.NewRequest(http.MethodPost, "/panic", strings.NewReader(`{}`))
+		req.Header.Set("X-Request-Id", "req-1")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status:%d", w.Code)
+		}
+		rsp := Response{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		if rsp.Code != Business_Code_Panic || rsp.Message != BusinessMessage_Panic {
+			t.Fatalf("response:%+v", rsp)
+		}
+		if len(log.errors) != 1 || !strings.Contains(log.errors[0], "requestId:req-1,panic:boom") || !strings.Contains(log.errors[0], "recovery_test.go") {
+			t.Fatalf("log:%v", log.errors)
+		}
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		log.errors = nil
+		req := httptest.NewRequest(http.MethodPost, "/writer-panic", strings.NewReader(`{}`))
+		req.Header.Set("X-Request-Id", "req-2")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status:%d", w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Fatalf("content type:%s", ct)
+		}
+		if w.Body.String() != "Internal Server Error,requestId:req-2" {
+			t.Fatalf("body:%s", w.Body.String())
+		}
+		if len(log.errors) != 2 {
+			t.Fatalf("log:%v", log.errors)
+		}
+	})
+
+	t.Run("gin", func(t *testing.T) {
+		log.errors = nil
+		req := httptest.NewRequest(http.MethodGet, "/raw/panic", nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), Business_Code_Panic) {
+			t.Fatalf("status:%d,body:%s", w.Code, w.Body.String())
+		}
+		if w.Header().Get("X-Request-Id") == "" {
+			t.Fatal("missing request id")
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package apihttpprotocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// RemoteError 客户端解析信封得到的服务端业务错误，实现 ErrorWithCode
+type RemoteError struct {
+	Code        string
+	Message     string
+	Details     any
+	Data        any // 响应中的数据部分
+	HttpCode    int
+	RequestId   string
+	CurlCommand string
+	target      error // RegisterRemoteError 注册的错误值
+	cause       error // 非200响应时的 ResponseError
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("response err code:%s,message:%s", e.Code, e.Message)
+}
+
+// Unwrap 返回业务码注册的错误值及原始 ResponseError，使 errors.Is(err, ErrXxx) 可跨服务判断
+func (e *RemoteError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	for _, err := range []error{e.target, e.cause} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (e *RemoteError) GetCode() string {
+	return e.Code
+}
+
+func (e *RemoteError) GetMessage() string {
+	return e.Message
+}
+
+func (e *RemoteError) GetDetails() any {
+	return e.Details
+}
+
+var (
+	remoteErrorMutex   sync.RWMutex
+	remoteErrorTargets = map[string]error{}
+)
+
+// RegisterRemoteError 注册业务码对应的错误值，同码覆盖
+func RegisterRemoteError(code string, target error) {
+	remoteErrorMutex.Lock()
+	defer remoteErrorMutex.Unlock()
+	remoteErrorTargets[code] = target
+}
+
+func getRemoteErrorTarget(code string) (target error) {
+	remoteErrorMutex.RLock()
+	defer remoteErrorMutex.RUnlock()
+	return remoteErrorTargets[code]
+}
+
+func newRemoteError(message *ResponseMessage, code string, msg string, details any, data any) *RemoteError {
+	remoteErr := &RemoteError{
+		Code:      code,
+		Message:   msg,
+		Details:   details,
+		Data:      data,
+		HttpCode:  message.HttpCode,
+		RequestId: message.GetRequestId(),
+		target:    getRemoteErrorTarget(code),
+	}
+	if requestMessage, ok := message.GetRequestMessage(); ok {
+		remoteErr.CurlCommand = requestMessage.CurlCommand()
+	}
+	return remoteErr
+}
+
+// remoteErrorFromResponseError 非200响应的响应体为业务信封(code/message、一层、二层协议)时，转换为 RemoteError
+func remoteErrorFromResponseError(message *ResponseMessage, err error) error {
+	var responseErr ResponseError
+	if !errors.As(err, &responseErr) {
+		return err
+	}
+	code, msg, details, data := errorEnvelopeOf([]byte(responseErr.Body))
+	if code == "" || code == Business_Code_Success {
+		return err
+	}
+	remoteErr := newRemoteError(message, code, msg, details, data)
+	remoteErr.cause = responseErr
+	return remoteErr
+}
+
+// errorEnvelopeOf 依次按 code/message、二层、一层协议解析响应体中的业务错误
+func errorEnvelopeOf(body []byte) (code string, msg string, details any, data any) {
+	response := &Response{}
+	if json.Unmarshal(body, response) == nil && response.Code != "" {
+		return response.Code, response.Message, response.Details, response.Data
+	}
+	twoLayer := &TwoLayerResponse{}
+	if json.Unmarshal(body, twoLayer) == nil && twoLayer.Data.ErrCode != "" {
+		return twoLayer.Data.ErrCode, twoLayer.Data.ErrStr, nil, twoLayer.Data.Data
+	}
+	oneLayer := &OneLayerResponse{}
+	if json.Unmarshal(body, oneLayer) == nil && oneLayer.ErrCode != "" {
+		return oneLayer.ErrCode, oneLayer.ErrStr, nil, oneLayer.Data
+	}
+	return "", "", nil, nil
+}
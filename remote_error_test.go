@@ -0,0 +1,61 @@
+package apihttpprotocol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var errOrderNotFound = errors.New("order not found")
+
+func TestRemoteError(t *testing.T) {
+	RegisterRemoteError("404100", errOrderNotFound)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	protoFn := func() *ServerProtocol {
+		p := NewServerProtocol()
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+		p.SetLog(LogIgnore{})
+		return p
+	}
+	engine.GET("/order", NewGinHander(protoFn, func(in map[string]any) (out map[string]string, err error) {
+		return map[string]string{"id": "1"}, NewCodeError("404100", "订单不存在")
+	}))
+	engine.GET("/order-http", NewGinHander(protoFn, func(in map[string]any) (out any, err error) {
+		return nil, NewCodeError("404100", "订单不存在").WithHttpStatus(http.StatusNotFound).WithDetails("id:1")
+	}))
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	cases := []struct {
+		path     string
+		httpCode int
+	}{
+		{"/order", http.StatusOK},
+		{"/order-http", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		client := NewClientProtocol(http.MethodGet, server.URL+c.path)
+		client.SetLog(LogIgnore{})
+		client.SetHeader("X-Request-Id", "req-remote")
+		client.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+		out := map[string]string{}
+		err := client.Do(nil, &out)
+		if !errors.Is(err, errOrderNotFound) {
+			t.Fatalf("%s: expected errOrderNotFound,got %v", c.path, err)
+		}
+		var remoteErr *RemoteError
+		if !errors.As(err, &remoteErr) {
+			t.Fatalf("%s: expected RemoteError,got %T", c.path, err)
+		}
+		if remoteErr.Code != "404100" || remoteErr.Message != "订单不存在" || remoteErr.HttpCode != c.httpCode || remoteErr.RequestId != "req-remote" || remoteErr.CurlCommand == "" {
+			t.Fatalf("%s: remote error:%+v", c.path, remoteErr)
+		}
+		if getBusinessCode(err) != "404100" {
+			t.Fatalf("%s: code lost", c.path)
+		}
+	}
+}
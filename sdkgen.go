@@ -0,0 +1,398 @@
+package apihttpprotocol
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// GoClientConfig Go 客户端 SDK 生成配置
+type GoClientConfig struct {
+	Package    string // 包名，默认 client
+	ClientName string // 客户端类型名，默认 Client
+}
+
+// LoadOpenAPIDocument 解析 json 或 yaml 格式的 OpenAPI 文档
+func LoadOpenAPIDocument(b []byte) (doc OpenAPIDocument, err error) {
+	err = yaml.Unmarshal(b, &doc) // yaml 兼容 json
+	if err != nil {
+		return doc, errors.WithMessage(err, "LoadOpenAPIDocument")
+	}
+	return doc, nil
+}
+
+// GenerateGoClient 根据已注册的接口生成 Go 客户端 SDK
+func (r *RouteRegistry) GenerateGoClient(config GoClientConfig) (code []byte, err error) {
+	return GenerateGoClient(r.OpenAPI(OpenAPIInfo{}), config)
+}
+
+// GenerateGoClient 根据 OpenAPI 文档生成基于 ClientProtocol 的 Go 客户端 SDK，按 x-envelope 选择协议中间件(缺省为 code/message/data)
+func GenerateGoClient(doc OpenAPIDocument, config GoClientConfig) (code []byte, err error) {
+	if config.Package == "" {
+		config.Package = "client"
+	}
+	if config.ClientName == "" {
+		config.ClientName = "Client"
+	}
+	g := &goClientGenerator{
+		config:    config,
+		imports:   map[string]bool{"strings": true, "github.com/suifengpiao14/apihttpprotocol": true},
+		typeNames: map[string]string{},
+		usedNames: map[string]bool{config.ClientName: true, "New" + config.ClientName: true},
+	}
+	return g.generate(doc)
+}
+
+type goClientGenerator struct {
+	config    GoClientConfig
+	imports   map[string]bool
+	typeNames map[string]string // components 中的名称 => Go 类型名
+	usedNames map[string]bool
+}
+
+// goName 转换为导出的 Go 标识符，如 get_order_id => GetOrderId
+func goName(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	name := b.String()
+	if name == "" {
+		return "Value"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "T" + name
+	}
+	return name
+}
+
+// goParamName 转换为非导出的参数名，避免与关键字及生成代码中的变量冲突
+func goParamName(s string) string {
+	runes := []rune(goName(s))
+	runes[0] = unicode.ToLower(runes[0])
+	name := string(runes)
+	switch name {
+	case "c", "in", "out", "err", "path", "client", "query", "b":
+		return name + "Param"
+	}
+	if token.IsKeyword(name) {
+		return name + "Param"
+	}
+	return name
+}
+
+// uniqueName 重名时追加序号
+func (g *goClientGenerator) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.usedNames[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	g.usedNames[unique] = true
+	return unique
+}
+
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, "#/components/schemas/")
+}
+
+// isEmptySchema 无返回数据(如 NewGinHanderCommand)
+func isEmptySchema(s *Schema) bool {
+	return s != nil && s.Nullable && s.Type == "" && s.Ref == ""
+}
+
+func (g *goClientGenerator) goType(s *Schema, indent string) string {
+	if s == nil {
+		return "any"
+	}
+	if s.Ref != "" {
+		if name, ok := g.typeNames[refName(s.Ref)]; ok {
+			return name
+		}
+		return "any"
+	}
+	t := ""
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			t = "time.Time"
+		case "byte":
+			return "[]byte"
+		default:
+			t = "string"
+		}
+	case "integer":
+		t = "int"
+		if s.Format == "int64" {
+			t = "int64"
+		}
+	case "number":
+		t = "float64"
+		if s.Format == "float" {
+			t = "float32"
+		}
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + g.goType(s.Items, indent)
+	case "object":
+		if len(s.Properties) > 0 {
+			return g.structType(s, indent)
+		}
+		if s.AdditionalProperties != nil {
+			return "map[string]" + g.goType(s.AdditionalProperties, indent)
+		}
+		return "map[string]any"
+	default:
+		return "any"
+	}
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func (g *goClientGenerator) structType(s *Schema, indent string) string {
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	used := map[string]bool{}
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, name := range names {
+		property := s.Properties[name]
+		fieldName := goName(name)
+		for i := 2; used[fieldName]; i++ {
+			fieldName = fmt.Sprintf("%s%d", goName(name), i)
+		}
+		used[fieldName] = true
+		tag := name
+		fieldType := g.goType(property, indent+"\t")
+		if !required[name] {
+			tag += ",omitempty"
+			if property != nil && property.Ref != "" { // 可选的结构体字段使用指针，同时支持递归引用
+				fieldType = "*" + fieldType
+			}
+		}
+		fmt.Fprintf(&b, "%s\t%s %s `json:%q`", indent, fieldName, fieldType, tag)
+		if property != nil && property.Description != "" {
+			fmt.Fprintf(&b, " // %s", strings.ReplaceAll(property.Description, "\n", " "))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// unwrapResponse 去掉协议外层，返回业务数据 Schema
+func unwrapResponse(envelope RouteEnvelope, s *Schema) *Schema {
+	if s == nil {
+		return nil
+	}
+	switch envelope {
+	case RouteEnvelope_None:
+		return s
+	case RouteEnvelope_OneLayer:
+		return s.Properties["_data"]
+	case RouteEnvelope_TwoLayer:
+		if data := s.Properties["_data"]; data != nil {
+			return data.Properties["_data"]
+		}
+		return nil
+	default:
+		return s.Properties["data"]
+	}
+}
+
+func (g *goClientGenerator) generate(doc OpenAPIDocument) (code []byte, err error) {
+	body := &bytes.Buffer{}
+	componentNames := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		componentNames = append(componentNames, name)
+	}
+	sort.Strings(componentNames)
+	for _, name := range componentNames { // 先分配类型名，支持类型间相互引用
+		g.typeNames[name] = g.uniqueName(goName(name))
+	}
+	for _, name := range componentNames {
+		fmt.Fprintf(body, "type %s %s\n\n", g.typeNames[name], g.goType(doc.Components.Schemas[name], ""))
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	twoLayer := false
+	for _, path := range paths {
+		methods := make([]string, 0, len(doc.Paths[path]))
+		for method := range doc.Paths[path] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			operation := doc.Paths[path][method]
+			if operation.Envelope == RouteEnvelope_TwoLayer {
+				twoLayer = true
+			}
+			err = g.operation(body, strings.ToUpper(method), path, operation)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "// Code generated by apisdkgen. DO NOT EDIT.\n\npackage %s\n\n", g.config.Package)
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	out.WriteString("import (\n")
+	for _, path := range imports {
+		fmt.Fprintf(out, "\t%q\n", path)
+	}
+	out.WriteString(")\n\n")
+	name := g.config.ClientName
+	fmt.Fprintf(out, "// %s %s 客户端\n", name, doc.Info.Title)
+	fmt.Fprintf(out, "type %s struct {\n\tBaseURL string\n", name)
+	if twoLayer {
+		out.WriteString("\tTwoLayerHead apihttpprotocol.TwoLayerHead // 二层协议请求头\n")
+	}
+	out.WriteString("\tSetup func(client *apihttpprotocol.ClientProtocol) // 每次请求前调用，可添加鉴权、日志等中间件\n}\n\n")
+	fmt.Fprintf(out, "func New%s(baseURL string) *%s {\n\treturn &%s{BaseURL: strings.TrimSuffix(baseURL, \"/\")}\n}\n\n", name, name, name)
+	fmt.Fprintf(out, "func (c *%s) newClientProtocol(method string, path string) *apihttpprotocol.ClientProtocol {\n", name)
+	out.WriteString("\tclient := apihttpprotocol.NewClientProtocol(method, c.BaseURL+path)\n")
+	out.WriteString("\tclient.SetHeader(\"Content-Type\", apihttpprotocol.ContentTypeJson)\n")
+	out.WriteString("\tif c.Setup != nil {\n\t\tc.Setup(client)\n\t}\n\treturn client\n}\n\n")
+	out.Write(body.Bytes())
+
+	code, err = format.Source(out.Bytes())
+	if err != nil {
+		return nil, errors.WithMessagef(err, "format generated code:\n%s", out.String())
+	}
+	return code, nil
+}
+
+func (g *goClientGenerator) operation(w *bytes.Buffer, method string, path string, operation *OpenAPIOperation) (err error) {
+	funcName := g.uniqueName(goName(operation.OperationId))
+	envelope := operation.Envelope
+	if envelope == "" {
+		envelope = RouteEnvelope_CodeMessage
+	}
+	args := make([]string, 0)
+	pathLines := make([]string, 0)
+	queryParams := make([]OpenAPIParameter, 0)
+	for _, parameter := range operation.Parameters {
+		switch parameter.In {
+		case "path":
+			name := goParamName(parameter.Name)
+			g.imports["net/url"] = true
+			args = append(args, name+" string")
+			pathLines = append(pathLines, fmt.Sprintf("path = strings.ReplaceAll(path, %q, url.PathEscape(%s))", "{"+parameter.Name+"}", name))
+		case "query":
+			queryParams = append(queryParams, parameter)
+		}
+	}
+	if len(queryParams) > 0 {
+		query := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, parameter := range queryParams {
+			query.Properties[parameter.Name] = parameter.Schema
+			if parameter.Required {
+				query.Required = append(query.Required, parameter.Name)
+			}
+		}
+		queryType := g.uniqueName(funcName + "Query")
+		fmt.Fprintf(w, "type %s %s\n\n", queryType, g.goType(query, ""))
+		args = append(args, "query "+queryType)
+	}
+	hasBody := false
+	if operation.RequestBody != nil {
+		media, ok := operation.RequestBody.Content[ContentTypeJson]
+		if ok {
+			input := media.Schema
+			if envelope == RouteEnvelope_TwoLayer && input != nil && input.Properties["_param"] != nil {
+				input = input.Properties["_param"]
+			}
+			args = append(args, "in "+g.goType(input, ""))
+			hasBody = true
+		}
+	}
+	var output *Schema
+	if response, ok := operation.Responses["200"]; ok {
+		if media, ok := response.Content[ContentTypeJson]; ok {
+			output = unwrapResponse(envelope, media.Schema)
+		}
+	}
+	command := output == nil || isEmptySchema(output)
+
+	comment := operation.Summary
+	if comment == "" {
+		comment = method + " " + path
+	}
+	fmt.Fprintf(w, "// %s %s\n", funcName, strings.ReplaceAll(comment, "\n", " "))
+	if command {
+		fmt.Fprintf(w, "func (c *%s) %s(%s) (err error) {\n", g.config.ClientName, funcName, strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(w, "func (c *%s) %s(%s) (out %s, err error) {\n", g.config.ClientName, funcName, strings.Join(args, ", "), g.goType(output, "\t"))
+	}
+	returnErr := "return out, err"
+	if command {
+		returnErr = "return err"
+	}
+	fmt.Fprintf(w, "\tpath := %q\n", path)
+	for _, line := range pathLines {
+		fmt.Fprintf(w, "\t%s\n", line)
+	}
+	if len(queryParams) > 0 {
+		fmt.Fprintf(w, "\tb, err := apihttpprotocol.FormCodec{}.Marshal(query)\n\tif err != nil {\n\t\t%s\n\t}\n", returnErr)
+		w.WriteString("\tif len(b) > 0 {\n\t\tpath += \"?\" + string(b)\n\t}\n")
+	}
+	fmt.Fprintf(w, "\tclient := c.newClientProtocol(%q, path)\n", method)
+	outType := "any" // 类型化信封中间件的业务数据类型
+	if !command {
+		outType = g.goType(output, "\t")
+	}
+	switch envelope {
+	case RouteEnvelope_OneLayer:
+		fmt.Fprintf(w, "\tclient.Response().AddMiddleware(apihttpprotocol.ResponseMiddleOneLayerForClientOf[%s])\n", outType)
+	case RouteEnvelope_TwoLayer:
+		if hasBody {
+			w.WriteString("\tclient.Request().AddMiddleware(apihttpprotocol.RequestMiddleTwoLayerForClient(c.TwoLayerHead))\n")
+		}
+		fmt.Fprintf(w, "\tclient.Response().AddMiddleware(apihttpprotocol.ResponseMiddleTwoLayerForClientOf[%s])\n", outType)
+	case RouteEnvelope_None:
+	default:
+		fmt.Fprintf(w, "\tclient.Response().AddMiddleware(apihttpprotocol.ResponseMiddleCodeMessageForClientOf[%s])\n", outType)
+	}
+	requestData := "nil"
+	if hasBody {
+		requestData = "in"
+	}
+	if command {
+		fmt.Fprintf(w, "\terr = client.Do(%s, nil)\n\treturn err\n}\n\n", requestData)
+	} else {
+		fmt.Fprintf(w, "\terr = client.Do(%s, &out)\n\treturn out, err\n}\n\n", requestData)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package apihttpprotocol
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sdkgenQuery struct {
+	Ids     []int     `json:"ids"`
+	Since   time.Time `json:"since"`
+	Keyword string    `json:"keyword,omitempty"`
+}
+
+func TestGenerateGoClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	registry := NewRouteRegistry()
+	RegisterGinHander(registry, engine, http.MethodPost, "/order", envelopeProtoFn(RouteEnvelope_CodeMessage), func(in openapiOrder) (out openapiOrder, err error) {
+		return in, nil
+	}, RouteDoc{Summary: "创建订单", OperationId: "createOrder"})
+	RegisterGinHander(registry, engine, http.MethodGet, "/order/:id", envelopeProtoFn(RouteEnvelope_OneLayer), func(in sdkgenQuery) (out PageOutput[openapiOrder], err error) {
+		id := fmt.Sprintf("%v|%s|%s", in.Ids, in.Since.Format(time.RFC3339), in.Keyword)
+		return NewPageOutput(PageInput{}, []openapiOrder{{Id: id}}, 1), nil
+	}, RouteDoc{})
+	RegisterGinHanderCommand(registry, engine, http.MethodPut, "/order/:id/cancel", envelopeProtoFn(RouteEnvelope_TwoLayer), func(in map[string]any) (err error) {
+		if in["reason"] != "test" {
+			return NewCodeError("400100", "reason required")
+		}
+		return nil
+	}, RouteDoc{})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	yamlDoc, err := registry.OpenAPI(OpenAPIInfo{Title: "order", Version: "1.0"}).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := LoadOpenAPIDocument(yamlDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := GenerateGoClient(doc, GoClientConfig{Package: "orderclient"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func (c *Client) CreateOrder(in OpenapiOrder) (out OpenapiOrder, err error)",
+		"apihttpprotocol.ResponseMiddleCodeMessageForClientOf[OpenapiOrder]",
+		"func (c *Client) GetOrderId(id string, query GetOrderIdQuery) (out PageOutputOpenapiOrder, err error)",
+		"apihttpprotocol.ResponseMiddleOneLayerForClientOf[PageOutputOpenapiOrder]",
+		"Ids     []int64   `json:\"ids\"`",
+		"func (c *Client) PutOrderIdCancel(id string, in map[string]any) (err error)",
+		"apihttpprotocol.RequestMiddleTwoLayerForClient(c.TwoLayerHead)",
+		"apihttpprotocol.ResponseMiddleTwoLayerForClientOf[any]",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Fatalf("generated code missing %q\n%s", want, code)
+		}
+	}
+	if testing.Short() {
+		t.Skip("skip building generated client in short mode")
+	}
+
+	// 生成到临时模块中编译，并通过生成的客户端请求服务端
+	root, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goSum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	modulePath := "github.com/suifengpiao14/apihttpprotocol"
+	goMod = []byte(strings.Replace(string(goMod), "module "+modulePath, "module sdkgentest", 1) +
+		fmt.Sprintf("\nrequire %s v0.0.0\n\nreplace %s => %s\n", modulePath, modulePath, root))
+	mainCode := `package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"sdkgentest/orderclient"
+)
+
+func main() {
+	c := orderclient.NewClient(os.Args[1])
+	order, err := c.CreateOrder(orderclient.OpenapiOrder{Id: "1", Amount: 2})
+	if err != nil {
+		panic(err)
+	}
+	page, err := c.GetOrderId("1", orderclient.GetOrderIdQuery{Ids: []int64{1, 2}, Since: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		panic(err)
+	}
+	err = c.PutOrderIdCancel("1", map[string]any{"reason": "test"})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(order.Id, ",", page.Items[0].Id, ",", c.PutOrderIdCancel("1", nil))
+}
+`
+	files := map[string][]byte{
+		"go.mod":                    goMod,
+		"go.sum":                    goSum,
+		"main.go":                   []byte(mainCode),
+		"orderclient/client_gen.go": code,
+	}
+	for name, b := range files {
+		filename := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filename, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "run", ".", server.URL)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s\n%s", err, out, code)
+	}
+	if want := "1,[1 2]|2024-05-01T00:00:00Z|,"; !strings.HasPrefix(string(out), want) || !strings.Contains(string(out), "400100") {
+		t.Fatalf("want %s,got %s", want, out)
+	}
+}
@@ -0,0 +1,298 @@
+package apihttpprotocol
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	Header_SignatureKeyId     = "X-Signature-Key-Id"
+	Header_SignatureTimestamp = "X-Signature-Timestamp"
+	Header_SignatureNonce     = "X-Signature-Nonce"
+	Header_Signature          = "X-Signature"
+)
+
+var (
+	Business_Code_Signature_Invalid = "401001"
+)
+
+// Signer 请求签名
+type Signer interface {
+	Sign(data []byte) (signature []byte, err error)
+}
+
+// Verifier 签名校验，签名不匹配时返回错误
+type Verifier interface {
+	Verify(data []byte, signature []byte) (err error)
+}
+
+var ErrSignatureMismatch = errors.New("signature mismatch")
+
+// HmacSigner HMAC-SHA256 签名，同时实现 Signer、Verifier
+type HmacSigner struct {
+	Secret []byte
+}
+
+func (s HmacSigner) Sign(data []byte) (signature []byte, err error) {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (s HmacSigner) Verify(data []byte, signature []byte) (err error) {
+	expected, _ := s.Sign(data)
+	if !hmac.Equal(expected, signature) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// RsaSigner RSA PKCS1v15 SHA256 签名
+type RsaSigner struct {
+	PrivateKey *rsa.PrivateKey
+}
+
+func (s RsaSigner) Sign(data []byte) (signature []byte, err error) {
+	digest := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA256, digest[:])
+}
+
+type RsaVerifier struct {
+	PublicKey *rsa.PublicKey
+}
+
+func (v RsaVerifier) Verify(data []byte, signature []byte) (err error) {
+	digest := sha256.Sum256(data)
+	err = rsa.VerifyPKCS1v15(v.PublicKey, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+type Ed25519Signer struct {
+	PrivateKey ed25519.PrivateKey
+}
+
+func (s Ed25519Signer) Sign(data []byte) (signature []byte, err error) {
+	return ed25519.Sign(s.PrivateKey, data), nil
+}
+
+type Ed25519Verifier struct {
+	PublicKey ed25519.PublicKey
+}
+
+func (v Ed25519Verifier) Verify(data []byte, signature []byte) (err error) {
+	if !ed25519.Verify(v.PublicKey, data, signature) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// SignatureContent 待签名内容: 方法、路径(含查询参数)、时间戳、随机数、请求体sha256，以换行符连接
+func SignatureContent(method string, rawUrl string, timestamp string, nonce string, body []byte) (content []byte, err error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	bodyHash := sha256.Sum256(body)
+	s := strings.Join([]string{
+		strings.ToUpper(method),
+		u.RequestURI(),
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	return []byte(s), nil
+}
+
+// RequestMiddleSign 客户端请求签名中间件，需放在修改请求体的中间件(如RequestMiddleEncodeBody)之后；
+// 服务发现、多地址容错改写了请求路径(如地址带 /api 前缀)时，在实际发送前按最终地址重新签名
+func RequestMiddleSign(keyId string, signer Signer) HandlerFunc[RequestMessage] {
+	return func(message *RequestMessage) (err error) {
+		body, err := message.EncodeBody()
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			message.GoStructRef = body // 使用编码后的请求体，确保签名内容与实际发送一致
+		}
+		message.SetRaw(body)
+		err = signHeaders(message.Headers, keyId, signer, message.Method, message.URL, body)
+		if err != nil {
+			return err
+		}
+		u, err := url.Parse(message.URL)
+		if err != nil {
+			return err
+		}
+		signedURI := u.RequestURI()
+		if responseMessage, ok := message.GetResponseMessage(); ok {
+			responseMessage.WrapDoInner(func(next DoFunc) DoFunc {
+				return func(req *http.Request) (*http.Response, error) {
+					if req.URL.RequestURI() == signedURI {
+						return next(req)
+					}
+					req = req.Clone(req.Context()) // 对冲请求并发执行，不修改共享的请求头
+					err := signHeaders(req.Header, keyId, signer, req.Method, req.URL.String(), body)
+					if err != nil {
+						return nil, err
+					}
+					return next(req)
+				}
+			})
+		}
+		return message.Next()
+	}
+}
+
+// signHeaders 计算签名并写入签名相关请求头
+func signHeaders(header http.Header, keyId string, signer Signer, method string, rawUrl string, body []byte) (err error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := newNonce()
+	content, err := SignatureContent(method, rawUrl, timestamp, nonce, body)
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(content)
+	if err != nil {
+		return err
+	}
+	header.Set(Header_SignatureKeyId, keyId)
+	header.Set(Header_SignatureTimestamp, timestamp)
+	header.Set(Header_SignatureNonce, nonce)
+	header.Set(Header_Signature, base64.StdEncoding.EncodeToString(signature))
+	return nil
+}
+
+func RequestMiddleSignHmac(keyId string, secret []byte) HandlerFunc[RequestMessage] {
+	return RequestMiddleSign(keyId, HmacSigner{Secret: secret})
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NonceStore 随机数存储，用于防重放
+type NonceStore interface {
+	// Add 记录nonce，已存在(重放)时返回false
+	Add(nonce string, ttl time.Duration) (ok bool)
+}
+
+// MemoryNonceStore 内存随机数存储，适用于单实例部署，多实例需实现基于redis等的 NonceStore
+type MemoryNonceStore struct {
+	mutex     sync.Mutex
+	items     map[string]time.Time
+	lastClean time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		items: map[string]time.Time{},
+	}
+}
+
+func (s *MemoryNonceStore) Add(nonce string, ttl time.Duration) (ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastClean) > ttl { // 定期清理过期数据
+		for k, expire := range s.items {
+			if now.After(expire) {
+				delete(s.items, k)
+			}
+		}
+		s.lastClean = now
+	}
+	expire, exists := s.items[nonce]
+	if exists && now.Before(expire) {
+		return false
+	}
+	s.items[nonce] = now.Add(ttl)
+	return true
+}
+
+// SignatureVerifyConfig 服务端签名校验配置
+type SignatureVerifyConfig struct {
+	VerifierLookup func(keyId string) (verifier Verifier, err error) // 根据密钥ID获取校验器
+	ClockSkew      time.Duration                                     // 允许的时钟偏差，默认5分钟
+	NonceStore     NonceStore                                        // 默认使用进程内共享的内存存储
+}
+
+var defaultNonceStore = NewMemoryNonceStore() // protoFn 每次请求都会创建中间件，默认存储需全局共享
+
+// RequestMiddleVerifySignature 服务端签名校验中间件，校验失败返回 Business_Code_Signature_Invalid
+func RequestMiddleVerifySignature(config SignatureVerifyConfig) HandlerFunc[RequestMessage] {
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = 5 * time.Minute
+	}
+	if config.NonceStore == nil {
+		config.NonceStore = defaultNonceStore
+	}
+	return func(message *RequestMessage) (err error) {
+		err = verifySignature(config, message)
+		if err != nil {
+			return NewCodeError(Business_Code_Signature_Invalid, err.Error()).WithHttpStatus(http.StatusUnauthorized)
+		}
+		return message.Next()
+	}
+}
+
+func verifySignature(config SignatureVerifyConfig, message *RequestMessage) (err error) {
+	keyId := message.GetHeader(Header_SignatureKeyId)
+	timestamp := message.GetHeader(Header_SignatureTimestamp)
+	nonce := message.GetHeader(Header_SignatureNonce)
+	signatureStr := message.GetHeader(Header_Signature)
+	if timestamp == "" || nonce == "" || signatureStr == "" {
+		return errors.New("signature missing")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Errorf("invalid signature timestamp:%s", timestamp)
+	}
+	skew := time.Since(time.Unix(unix, 0))
+	if skew > config.ClockSkew || skew < -config.ClockSkew {
+		return errors.Errorf("signature timestamp expired:%s", timestamp)
+	}
+	signature, err := base64.StdEncoding.DecodeString(signatureStr)
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	if config.VerifierLookup == nil {
+		return errors.New("signature verifier not configured")
+	}
+	verifier, err := config.VerifierLookup(keyId)
+	if err != nil {
+		return err
+	}
+	content, err := SignatureContent(message.Method, message.URL, timestamp, nonce, message.GetRaw())
+	if err != nil {
+		return err
+	}
+	err = verifier.Verify(content, signature)
+	if err != nil {
+		return err
+	}
+	// 签名通过后再记录nonce，避免伪造请求占用nonce
+	if !config.NonceStore.Add(fmt.Sprintf("%s:%s", keyId, nonce), 2*config.ClockSkew) {
+		return errors.Errorf("signature nonce replayed:%s", nonce)
+	}
+	return nil
+}
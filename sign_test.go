@@ -0,0 +1,96 @@
+package apihttpprotocol
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type signOrder struct {
+	OrderId string `json:"orderId"`
+}
+
+// newSignatureTestServer 验签后原样返回订单的测试服务
+func newSignatureTestServer(t *testing.T, path string, verifierLookup func(keyId string) (verifier Verifier, err error)) *httptest.Server {
+	protoFn := testProtoFn(func(p *ServerProtocol) {
+		p.Request().AddMiddleware(RequestMiddleVerifySignature(SignatureVerifyConfig{VerifierLookup: verifierLookup}))
+		p.Response().AddMiddleware(ResponseMiddleCodeMessageForServer)
+	})
+	return newTestServer(t, func(engine *gin.Engine) {
+		engine.POST(path, NewGinHander(protoFn, func(in signOrder) (out signOrder, err error) {
+			return in, nil
+		}))
+	})
+}
+
+func TestSignature(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	verifiers := map[string]Verifier{
+		"hmac":    HmacSigner{Secret: []byte("secret")},
+		"ed25519": Ed25519Verifier{PublicKey: publicKey},
+	}
+	server := newSignatureTestServer(t, "/order", func(keyId string) (verifier Verifier, err error) {
+		verifier, ok := verifiers[keyId]
+		if !ok {
+			return nil, errors.Errorf("unknown key id:%s", keyId)
+		}
+		return verifier, nil
+	})
+
+	signers := map[string]Signer{
+		"hmac":    HmacSigner{Secret: []byte("secret")},
+		"ed25519": Ed25519Signer{PrivateKey: privateKey},
+	}
+	for keyId, signer := range signers {
+		client := NewClientProtocol("POST", server.URL+"/order?v=1")
+		client.SetLog(LogIgnore{})
+		client.SetHeader("Content-Type", ContentTypeJson)
+		client.Request().AddMiddleware(RequestMiddleSign(keyId, signer))
+		client.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+		var out signOrder
+		err := client.Do(signOrder{OrderId: "12"}, &out)
+		if err != nil {
+			t.Fatal(keyId, err)
+		}
+		if out.OrderId != "12" {
+			t.Fatalf("%s: unexpected %+v", keyId, out)
+		}
+
+		// 重放相同请求应被拒绝
+		replay, _ := client.Request().GetDuplicateRequest()
+		rsp, err := http.DefaultClient.Do(replay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		var envelope Response
+		_ = json.Unmarshal(body, &envelope)
+		if rsp.StatusCode != http.StatusUnauthorized || envelope.Code != Business_Code_Signature_Invalid {
+			t.Fatalf("%s: replay should be rejected,got %d %s", keyId, rsp.StatusCode, string(body))
+		}
+	}
+}
+
+func TestSignatureEndpointPrefix(t *testing.T) {
+	server := newSignatureTestServer(t, "/api/order", func(keyId string) (verifier Verifier, err error) {
+		return HmacSigner{Secret: []byte("secret")}, nil
+	})
+
+	client := NewClientProtocol("POST", "http://order-service/order?v=1") // 地址带 /api 前缀，需按最终请求路径签名
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeJson)
+	client.Request().AddMiddleware(RequestMiddleSignHmac("hmac", []byte("secret")))
+	client.Response().AddMiddleware(ResponseMiddleFailover(FailoverConfig{Endpoints: []string{server.URL + "/api"}}), ResponseMiddleCodeMessageForClient)
+	var out signOrder
+	err := client.Do(signOrder{OrderId: "12"}, &out)
+	if err != nil || out.OrderId != "12" {
+		t.Fatalf("out:%+v,err:%v", out, err)
+	}
+}
@@ -0,0 +1,105 @@
+package apihttpprotocol
+
+import (
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MetaData_Coalesced = "coalesced" // 是否复用了其它协程进行中的请求结果
+)
+
+type inflightCall struct {
+	done     chan struct{}
+	waiters  int // 等待共享结果的请求数
+	response *CachedResponse
+	err      error
+}
+
+// SingleflightGroup 合并进行中的相同请求，需全局共享(不要在每次请求时创建)
+type SingleflightGroup struct {
+	VaryHeaders []string // 参与key计算的请求头，如 Accept，CredentialHeaders() 始终参与计算，避免不同身份的请求被合并
+	mutex       sync.Mutex
+	calls       map[string]*inflightCall
+}
+
+func NewSingleflightGroup(varyHeaders ...string) *SingleflightGroup {
+	return &SingleflightGroup{
+		VaryHeaders: varyHeaders,
+		calls:       map[string]*inflightCall{},
+	}
+}
+
+// do 相同key只有首个请求访问网络，其余等待并共享原始响应
+func (g *SingleflightGroup) do(key string, fn func() (*CachedResponse, error)) (response *CachedResponse, shared bool, err error) {
+	g.mutex.Lock()
+	if call, ok := g.calls[key]; ok {
+		call.waiters++
+		g.mutex.Unlock()
+		<-call.done
+		return call.response, true, call.err
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(call.done)
+	}()
+	call.run(fn)
+	return call.response, false, call.err
+}
+
+// run 执行共享请求，panic 转换为错误返回给所有等待者，避免等待者拿到空响应
+func (call *inflightCall) run(fn func() (*CachedResponse, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.response, call.err = nil, errors.Errorf("singleflight call panic:%v\n%s", r, debug.Stack())
+		}
+	}()
+	call.response, call.err = fn()
+}
+
+// ResponseMiddleSingleflight 客户端请求合并，相同方法、URL、请求体的并发请求只发起一次，共享的原始body由各自的中间件链独立解码到 GoStructRef
+// 注意：合并后的请求受首个请求的 context 控制
+func ResponseMiddleSingleflight(group *SingleflightGroup) HandlerFunc[ResponseMessage] {
+	return func(message *ResponseMessage) (err error) {
+		message.WrapDo(func(next DoFunc) DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				key, err := CacheKey(req, withCredentialHeaders(group.VaryHeaders, nil))
+				if err != nil {
+					return nil, err
+				}
+				cached, shared, err := group.do(key, func() (*CachedResponse, error) {
+					response, err := next(req)
+					if err != nil {
+						return nil, err
+					}
+					defer response.Body.Close()
+					body, err := io.ReadAll(response.Body)
+					if err != nil {
+						return nil, err
+					}
+					return &CachedResponse{
+						StatusCode: response.StatusCode,
+						Header:     response.Header.Clone(),
+						Body:       body,
+					}, nil
+				})
+				message.SetMetaData(MetaData_Coalesced, shared)
+				if err != nil {
+					return nil, err
+				}
+				return cached.toResponse(req), nil
+			}
+		})
+		return message.Next()
+	}
+}
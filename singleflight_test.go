@@ -0,0 +1,148 @@
+package apihttpprotocol
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleflight(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"0","message":"success","data":{"id":1}}`))
+	}))
+	defer server.Close()
+
+	group := NewSingleflightGroup()
+	var wg sync.WaitGroup
+	outs := make([]map[string]any, 10)
+	errs := make([]error, 10)
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client := NewClientProtocol("GET", server.URL+"/item")
+			client.SetLog(LogIgnore{})
+			client.Response().AddMiddleware(ResponseMiddleSingleflight(group), ResponseMiddleCodeMessageForClient)
+			errs[i] = client.Do(nil, &outs[i])
+		}(i)
+	}
+	wg.Wait()
+	for i := range outs {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if outs[i]["id"] != float64(1) {
+			t.Fatalf("unexpected out %v", outs[i])
+		}
+	}
+	if hits.Load() >= 10 {
+		t.Fatalf("requests should be coalesced,hits:%d", hits.Load())
+	}
+}
+
+// inflight key 对应的请求是否进行中
+func (g *SingleflightGroup) inflight(key string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
+
+// waiters key 对应的进行中请求的等待数
+func (g *SingleflightGroup) waiters(key string) int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if call, ok := g.calls[key]; ok {
+		return call.waiters
+	}
+	return 0
+}
+
+func TestSingleflightCredentials(t *testing.T) {
+	var hits atomic.Int32
+	arrived := make(chan struct{}, 2)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		arrived <- struct{}{}
+		<-release
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"0","message":"success","data":{"token":"` + r.Header.Get("Authorization") + `"}}`))
+	}))
+	defer server.Close()
+
+	group := NewSingleflightGroup()
+	var wg sync.WaitGroup
+	outs := make([]map[string]any, 2)
+	errs := make([]error, 2)
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client := NewClientProtocol("GET", server.URL+"/profile")
+			client.SetLog(LogIgnore{})
+			client.SetHeader("Authorization", fmt.Sprintf("Bearer user%d", i))
+			client.Response().AddMiddleware(ResponseMiddleSingleflight(group), ResponseMiddleCodeMessageForClient)
+			errs[i] = client.Do(nil, &outs[i])
+		}(i)
+	}
+	<-arrived // 两个请求都到达上游后才响应，不同凭证的请求不能被合并
+	<-arrived
+	close(release)
+	wg.Wait()
+	for i := range outs {
+		if errs[i] != nil || outs[i]["token"] != fmt.Sprintf("Bearer user%d", i) {
+			t.Fatalf("request %d: out:%v,err:%v", i, outs[i], errs[i])
+		}
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("requests with different credentials should not be coalesced,hits:%d", hits.Load())
+	}
+}
+
+func TestSingleflightPanic(t *testing.T) {
+	group := NewSingleflightGroup()
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, errs[0] = group.do("key", func() (*CachedResponse, error) {
+			<-release
+			panic("boom")
+		})
+	}()
+	for !group.inflight("key") { // 等待首个请求登记后再发起其余请求
+		runtime.Gosched()
+	}
+	for i := 1; i < len(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, errs[i] = group.do("key", func() (*CachedResponse, error) {
+				return &CachedResponse{StatusCode: http.StatusOK}, nil
+			})
+		}(i)
+	}
+	for group.waiters("key") < len(errs)-1 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	for i, err := range errs {
+		if err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Fatalf("call %d: expected panic error,got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package apihttpprotocol
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"moul.io/http2curl"
+)
+
+const (
+	SnippetFormat_Curl       = "curl"
+	SnippetFormat_HTTPie     = "httpie"
+	SnippetFormat_PowerShell = "powershell"
+	SnippetFormat_Go         = "go"
+	SnippetFormat_Raw        = "http" // HTTP/1.1 报文
+)
+
+// SnippetFormat RequestMiddleLog 及 ResponseError.Snippet 使用的重放代码格式
+var SnippetFormat = SnippetFormat_Curl
+
+var ErrSnippetFormatNotSupport = errors.New("snippet format not support")
+
+// SnippetExporter 将请求导出为可重放的代码片段
+type SnippetExporter interface {
+	Format() string
+	Export(req *http.Request) (snippet string, err error)
+}
+
+var (
+	snippetExporterMutex sync.RWMutex
+	snippetExporters     = map[string]SnippetExporter{}
+)
+
+// RegisterSnippetExporter 注册导出器，同名覆盖
+func RegisterSnippetExporter(exporter SnippetExporter) {
+	snippetExporterMutex.Lock()
+	defer snippetExporterMutex.Unlock()
+	snippetExporters[exporter.Format()] = exporter
+}
+
+func GetSnippetExporter(format string) (exporter SnippetExporter, ok bool) {
+	snippetExporterMutex.RLock()
+	defer snippetExporterMutex.RUnlock()
+	exporter, ok = snippetExporters[format]
+	return exporter, ok
+}
+
+// ExportSnippet 按格式导出请求
+func ExportSnippet(format string, req *http.Request) (snippet string, err error) {
+	exporter, ok := GetSnippetExporter(format)
+	if !ok {
+		return "", errors.WithMessagef(ErrSnippetFormatNotSupport, "format:%s", format)
+	}
+	return exporter.Export(req)
+}
+
+func init() {
+	RegisterSnippetExporter(CurlExporter{})
+	RegisterSnippetExporter(HTTPieExporter{})
+	RegisterSnippetExporter(PowerShellExporter{})
+	RegisterSnippetExporter(GoExporter{})
+	RegisterSnippetExporter(RawHttpExporter{})
+}
+
+// Snippet 将复制的请求导出为指定格式的重放代码，失败时返回错误信息
+func (m *RequestMessage) Snippet(format string) string {
+	req, exists := m.GetDuplicateRequest()
+	if !exists {
+		return ""
+	}
+	snippet, err := ExportSnippet(format, req)
+	if err != nil {
+		return err.Error()
+	}
+	return snippet
+}
+
+// snippetBody 读取请求体并恢复，便于多次导出
+func snippetBody(req *http.Request) (body string, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(strings.NewReader(string(b)))
+	return string(b), nil
+}
+
+// snippetHeaderNames 请求头按名称排序，保证输出稳定
+func snippetHeaderNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// shellQuote 单引号转义，适用于 bash/zsh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type CurlExporter struct{}
+
+func (CurlExporter) Format() string {
+	return SnippetFormat_Curl
+}
+
+func (CurlExporter) Export(req *http.Request) (snippet string, err error) {
+	curlCommand, err := http2curl.GetCurlCommand(req)
+	if err != nil {
+		return "", err
+	}
+	return curlCommand.String(), nil
+}
+
+// HTTPieExporter 导出 HTTPie 命令，请求体通过标准输入传递以保持原样
+type HTTPieExporter struct{}
+
+func (HTTPieExporter) Format() string {
+	return SnippetFormat_HTTPie
+}
+
+func (HTTPieExporter) Export(req *http.Request) (snippet string, err error) {
+	body, err := snippetBody(req)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0)
+	if body != "" {
+		parts = append(parts, "printf '%s'", shellQuote(body), "|")
+	}
+	parts = append(parts, "http", req.Method, shellQuote(req.URL.String()))
+	for _, name := range snippetHeaderNames(req.Header) {
+		for _, value := range req.Header[name] {
+			parts = append(parts, shellQuote(name+":"+value))
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// PowerShellExporter 导出 PowerShell Invoke-WebRequest 命令
+type PowerShellExporter struct{}
+
+func (PowerShellExporter) Format() string {
+	return SnippetFormat_PowerShell
+}
+
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (PowerShellExporter) Export(req *http.Request) (snippet string, err error) {
+	body, err := snippetBody(req)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{"Invoke-WebRequest", "-Uri", powerShellQuote(req.URL.String()), "-Method", req.Method}
+	headers := make([]string, 0)
+	contentType := ""
+	for _, name := range snippetHeaderNames(req.Header) {
+		if strings.EqualFold(name, "Content-Type") { // Content-Type 需通过 -ContentType 设置
+			contentType = req.Header.Get(name)
+			continue
+		}
+		headers = append(headers, fmt.Sprintf("%s = %s", powerShellQuote(name), powerShellQuote(strings.Join(req.Header[name], ", "))))
+	}
+	if len(headers) > 0 {
+		parts = append(parts, "-Headers", "@{ "+strings.Join(headers, "; ")+" }")
+	}
+	if contentType != "" {
+		parts = append(parts, "-ContentType", powerShellQuote(contentType))
+	}
+	if body != "" {
+		parts = append(parts, "-Body", powerShellQuote(body))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// GoExporter 导出使用 ClientProtocol 的 Go 代码
+type GoExporter struct{}
+
+func (GoExporter) Format() string {
+	return SnippetFormat_Go
+}
+
+func (GoExporter) Export(req *http.Request) (snippet string, err error) {
+	body, err := snippetBody(req)
+	if err != nil {
+		return "", err
+	}
+	w := &strings.Builder{}
+	fmt.Fprintf(w, "client := apihttpprotocol.NewClientProtocol(%q, %q)\n", req.Method, req.URL.String())
+	for _, name := range snippetHeaderNames(req.Header) {
+		for _, value := range req.Header[name] {
+			fmt.Fprintf(w, "client.SetHeader(%q, %q)\n", name, value)
+		}
+	}
+	fmt.Fprintf(w, "var out any\n")
+	if body != "" {
+		fmt.Fprintf(w, "err := client.Do([]byte(%q), &out)\n", body)
+	} else {
+		fmt.Fprintf(w, "err := client.Do(nil, &out)\n")
+	}
+	return w.String(), nil
+}
+
+// RawHttpExporter 导出 HTTP/1.1 报文，可用于 nc、IDE http client 等工具
+type RawHttpExporter struct{}
+
+func (RawHttpExporter) Format() string {
+	return SnippetFormat_Raw
+}
+
+func (RawHttpExporter) Export(req *http.Request) (snippet string, err error) {
+	body, err := snippetBody(req)
+	if err != nil {
+		return "", err
+	}
+	w := &strings.Builder{}
+	fmt.Fprintf(w, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(w, "Host: %s\r\n", host)
+	for _, name := range snippetHeaderNames(req.Header) {
+		if strings.EqualFold(name, "Host") || strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+		for _, value := range req.Header[name] {
+			fmt.Fprintf(w, "%s: %s\r\n", name, value)
+		}
+	}
+	if body != "" {
+		fmt.Fprintf(w, "Content-Length: %d\r\n", len(body))
+	}
+	fmt.Fprintf(w, "\r\n%s", body)
+	return w.String(), nil
+}
@@ -0,0 +1,47 @@
+package apihttpprotocol
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSnippetExporters(t *testing.T) {
+	newReq := func() *http.Request {
+		req, _ := http.NewRequest("POST", "http://api.local/order?id=1", strings.NewReader(`{"name":"it's"}`))
+		req.Header.Set("Content-Type", ContentTypeJson)
+		req.Header.Set("X-Request-Id", "r1")
+		return req
+	}
+
+	raw, err := ExportSnippet(SnippetFormat_Raw, newReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(parsed.Body)
+	if parsed.Host != "api.local" || parsed.URL.RequestURI() != "/order?id=1" || string(body) != `{"name":"it's"}` {
+		t.Fatalf("unexpected raw snippet %q", raw)
+	}
+
+	httpie, _ := ExportSnippet(SnippetFormat_HTTPie, newReq())
+	if httpie != `printf '%s' '{"name":"it'\''s"}' | http POST 'http://api.local/order?id=1' 'Content-Type:application/json' 'X-Request-Id:r1'` {
+		t.Fatalf("unexpected httpie snippet %s", httpie)
+	}
+	powershell, _ := ExportSnippet(SnippetFormat_PowerShell, newReq())
+	if !strings.Contains(powershell, `-ContentType 'application/json'`) || !strings.Contains(powershell, `-Body '{"name":"it''s"}'`) {
+		t.Fatalf("unexpected powershell snippet %s", powershell)
+	}
+	goCode, _ := ExportSnippet(SnippetFormat_Go, newReq())
+	if !strings.Contains(goCode, `apihttpprotocol.NewClientProtocol("POST", "http://api.local/order?id=1")`) {
+		t.Fatalf("unexpected go snippet %s", goCode)
+	}
+	if _, err = ExportSnippet("unknown", newReq()); err == nil {
+		t.Fatal("unknown format should fail")
+	}
+}
@@ -0,0 +1,416 @@
+package apihttpprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	ContentTypeEventStream = "text/event-stream"
+	ContentTypeNDJson      = "application/x-ndjson"
+)
+
+const (
+	MetaData_StreamEventId   = "stream_event_id"
+	MetaData_StreamEventName = "stream_event_name"
+)
+
+// StreamFormat 流式响应格式
+type StreamFormat string
+
+const (
+	StreamFormat_SSE    StreamFormat = "sse"    // Server-Sent Events
+	StreamFormat_NDJson StreamFormat = "ndjson" // 每行一个json
+)
+
+func (f StreamFormat) ContentType() string {
+	if f == StreamFormat_NDJson {
+		return ContentTypeNDJson
+	}
+	return ContentTypeEventStream
+}
+
+// NegotiateStreamFormat 根据Accept或Content-Type头确定流格式，无法识别时默认SSE
+func NegotiateStreamFormat(headerValue string) StreamFormat {
+	if strings.Contains(headerValue, ContentTypeNDJson) && !strings.Contains(headerValue, ContentTypeEventStream) {
+		return StreamFormat_NDJson
+	}
+	return StreamFormat_SSE
+}
+
+// StreamEvent 流中的单个事件
+type StreamEvent struct {
+	Id    string
+	Event string
+	Data  []byte
+}
+
+type streamDecoder interface {
+	Next() (event StreamEvent, err error) // 结束时返回io.EOF
+}
+
+func newStreamDecoder(format StreamFormat, r io.Reader) streamDecoder {
+	reader := bufio.NewReader(r)
+	if format == StreamFormat_NDJson {
+		return &ndjsonDecoder{reader: reader}
+	}
+	return &sseDecoder{reader: reader}
+}
+
+type sseDecoder struct {
+	reader *bufio.Reader
+}
+
+func (d *sseDecoder) Next() (event StreamEvent, err error) {
+	var data [][]byte
+	for {
+		line, err := d.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF && len(data) > 0 { // 最后一个事件没有空行结尾
+				event.Data = bytes.Join(data, []byte("\n"))
+				return event, nil
+			}
+			return event, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" { // 空行表示一个事件结束
+			if len(data) == 0 {
+				continue
+			}
+			event.Data = bytes.Join(data, []byte("\n"))
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") { // 注释行，一般用于心跳
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, []byte(value))
+		case "id":
+			event.Id = value
+		case "event":
+			event.Event = value
+		}
+	}
+}
+
+type ndjsonDecoder struct {
+	reader *bufio.Reader
+}
+
+func (d *ndjsonDecoder) Next() (event StreamEvent, err error) {
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return event, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		event.Data = line
+		return event, nil
+	}
+}
+
+// StreamReader 客户端流式响应读取器，每个事件都会经过响应中间件调用链
+type StreamReader struct {
+	protocol     *ClientProtocol
+	request      *http.Request
+	httpClient   *http.Client
+	httpResponse *http.Response
+	decoder      streamDecoder
+	cancel       context.CancelFunc
+}
+
+// Stream 发送请求并以流(SSE/NDJSON)的方式读取响应，使用完毕后需调用Close。
+// 连接在首次调用 Next 时于响应中间件调用链末端建立，中间件注册的 WrapDo(刷新令牌、限流、熔断、多地址容错、HAR等)对流同样生效，非200响应由首次 Next 返回
+func (c *ClientProtocol) Stream(requestData any) (stream *StreamReader, err error) {
+	if c.httpClient == nil {
+		err = errors.Errorf("ClientProtocol.Stream: http client is nil")
+		return nil, err
+	}
+	if c.request.GetHeader("Accept") == "" {
+		c.request.SetHeader("Accept", ContentTypeEventStream+", "+ContentTypeNDJson)
+	}
+	err = c._WriteRequest(requestData)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := c.request.GetDuplicateRequest()
+	if !ok {
+		err = errors.Errorf("ClientProtocol.Stream: request not prepared")
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0 // 流式响应持续时间不确定，由调用方通过Close结束
+	stream = &StreamReader{
+		protocol:   c,
+		request:    req.WithContext(ctx),
+		httpClient: &streamClient,
+		cancel:     cancel,
+	}
+	return stream, nil
+}
+
+// open 建立连接，经过响应中间件注册的 WrapDo
+func (s *StreamReader) open(message *ResponseMessage) (err error) {
+	response, err := message.buildDo(s.httpClient.Do)(s.request)
+	if err != nil {
+		return err
+	}
+	message.HttpCode = response.StatusCode
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		message.SetRaw(body)
+		_ = message.SetDuplicateResponse(response, body)
+		requestMessage := s.protocol.request
+		responseError := ResponseError{
+			HttpCode:    response.StatusCode,
+			CurlCommand: requestMessage.CurlCommand(),
+			Snippet:     responseErrorSnippet(requestMessage),
+			Body:        string(body),
+		}
+		return responseError
+	}
+	s.httpResponse = response
+	s.decoder = newStreamDecoder(NegotiateStreamFormat(response.Header.Get("Content-Type")), response.Body)
+	return nil
+}
+
+// Next 读取下一个事件并解码到dst，流结束时返回io.EOF
+func (s *StreamReader) Next(dst any) (err error) {
+	response := s.protocol.response
+	response.GoStructRef = dst
+	defer func() {
+		response.doWrappers, response.innerDoWrappers = nil, nil // 连接只建立一次，后续事件注册的包装不再使用
+	}()
+	if s.decoder == nil {
+		return response.runWith(s.openAndDecode)
+	}
+	event, err := s.decoder.Next()
+	if err != nil {
+		return err
+	}
+	err = s.setEvent(response, event)
+	if err != nil {
+		return err
+	}
+	return response.runWith(decodeStreamEvent)
+}
+
+// openAndDecode 首个事件的调用链末端，建立连接后读取并解码第一个事件
+func (s *StreamReader) openAndDecode(message *ResponseMessage) (err error) {
+	err = s.open(message)
+	if err != nil {
+		return err
+	}
+	event, err := s.decoder.Next()
+	if err != nil {
+		return err
+	}
+	err = s.setEvent(message, event)
+	if err != nil {
+		return err
+	}
+	return decodeStreamEvent(message)
+}
+
+func (s *StreamReader) setEvent(message *ResponseMessage, event StreamEvent) (err error) {
+	message.SetRaw(event.Data)
+	message.SetMetaData(MetaData_StreamEventId, event.Id)
+	message.SetMetaData(MetaData_StreamEventName, event.Event)
+	return message.SetDuplicateResponse(s.httpResponse, event.Data) // 传入事件数据，复制时不会读取流本身
+}
+
+func (s *StreamReader) Close() (err error) {
+	defer s.cancel()
+	if s.httpResponse == nil {
+		return nil
+	}
+	return s.httpResponse.Body.Close()
+}
+
+// decodeStreamEvent 流式响应调用链末端，将单个事件解码到GoStructRef
+func decodeStreamEvent(message *ResponseMessage) (err error) {
+	body := message.GetRaw()
+	if message.GoStructRef != nil && len(body) > 0 {
+		err = DefaultCodec().Unmarshal(body, message.GoStructRef)
+		if err != nil {
+			err = errors.WithMessagef(err, "stream event Unmarshal,body:%s", string(body))
+			return err
+		}
+	}
+	return message.Next()
+}
+
+// StreamIterator 泛型流迭代器，用法:
+//
+//	it, err := StreamOf[Item](client, req)
+//	defer it.Close()
+//	for it.Next() { item := it.Value() }
+//	err = it.Err()
+type StreamIterator[T any] struct {
+	reader *StreamReader
+	value  T
+	err    error
+}
+
+func StreamOf[T any](c *ClientProtocol, requestData any) (it *StreamIterator[T], err error) {
+	reader, err := c.Stream(requestData)
+	if err != nil {
+		return nil, err
+	}
+	it = &StreamIterator[T]{reader: reader}
+	return it, nil
+}
+
+func (it *StreamIterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	var value T
+	err := it.reader.Next(&value)
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			it.err = err
+		}
+		return false
+	}
+	it.value = value
+	return true
+}
+
+func (it *StreamIterator[T]) Value() T {
+	return it.value
+}
+
+func (it *StreamIterator[T]) Err() error {
+	return it.err
+}
+
+func (it *StreamIterator[T]) Close() error {
+	return it.reader.Close()
+}
+
+func (p *ServerProtocol) WithStreamWriter(writer HandlerFuncResponseMessage) *ServerProtocol {
+	p.streamWriter = writer
+	return p
+}
+
+// ResponseStream 流式输出，next 每次返回一个事件，ok=false 时结束；每个事件都会经过响应中间件调用链
+func (p *ServerProtocol) ResponseStream(next func() (data any, ok bool)) (err error) {
+	if p.streamWriter == nil {
+		return ERRIOFnIsNil
+	}
+	response := p.Response()
+	for {
+		data, ok := next()
+		if !ok {
+			return nil
+		}
+		response.GoStructRef = data
+		err = response.runWith(p.streamWriter)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// NewGinStreamWriter gin 流式输出函数，每次调用输出一个事件并立即刷新
+func NewGinStreamWriter(c *gin.Context, format StreamFormat) (writeFn HandlerFuncResponseMessage) {
+	eventId := 0
+	writeFn = func(message *ResponseMessage) (err error) {
+		requestId := message.GetRequestId()
+		if eventId == 0 {
+			c.Header("Content-Type", format.ContentType())
+			c.Header("Cache-Control", "no-cache")
+			c.Header("X-Request-Id", requestId)
+			c.Status(http.StatusOK)
+		}
+		eventId++
+		var b []byte
+		if message.GoStructRef != nil {
+			b, err = DefaultCodec().Marshal(message.GoStructRef)
+			if err != nil {
+				return err
+			}
+		}
+		switch format {
+		case StreamFormat_NDJson:
+			_, err = c.Writer.Write(append(b, '\n'))
+		default:
+			err = sse.Encode(c.Writer, sse.Event{
+				Id:   strconv.Itoa(eventId),
+				Data: string(b),
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Writer.Flush()
+
+		duplicateResponse := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+		}
+		if message.requestMessage != nil {
+			duplicateResponse.Request, _ = message.requestMessage.GetDuplicateRequest()
+		}
+		duplicateResponse.Header.Set("Content-Type", format.ContentType())
+		duplicateResponse.Header.Set("X-Request-Id", requestId)
+		message.SetDuplicateResponse(duplicateResponse, b)
+		return nil
+	}
+	return writeFn
+}
+
+// NewGinStreamHander 流式接口，handler 返回的channel关闭(或为nil)、客户端断开时结束输出，输出格式由请求头Accept决定
+func NewGinStreamHander[I any, O any](protoFn func() *ServerProtocol, handler func(ctx context.Context, in I) (events <-chan O, err error)) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		proto := protoFn() //每次请求需要重新创建协议对象，防止并发安全问题
+		proto.WithIOFn(NewGinReadWriteMiddleware(c)).WithHttpRequest(c.Request).WithHttpResponseWriter(c.Writer)
+		defer proto.Recover()
+		proto.WithStreamWriter(NewGinStreamWriter(c, NegotiateStreamFormat(c.GetHeader("Accept"))))
+		var in I
+		err := proto.ReadRequest(&in)
+		if err != nil {
+			proto.ResponseFail(err)
+			return
+		}
+		ctx := c.Request.Context()
+		events, err := handler(ctx, in)
+		if err != nil {
+			proto.ResponseFail(err)
+			return
+		}
+		err = proto.ResponseStream(func() (data any, ok bool) {
+			if events == nil { // 未返回channel视为没有事件，直接结束
+				return nil, false
+			}
+			select {
+			case <-ctx.Done():
+				return nil, false
+			case event, ok := <-events:
+				return event, ok
+			}
+		})
+		if err != nil { // 已开始输出，无法再返回错误响应，只记录日志
+			proto.Response().GetLog().Error(fmt.Sprintf("requestId:%s,response stream error:%s", proto.Response().GetRequestId(), err.Error()))
+		}
+	}
+}
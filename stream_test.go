@@ -0,0 +1,136 @@
+package apihttpprotocol
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type streamItem struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func newStreamTestServer(t *testing.T) *httptest.Server {
+	protoFn := envelopeProtoFn(RouteEnvelope_CodeMessage)
+	return newTestServer(t, func(engine *gin.Engine) {
+		engine.POST("/stream", NewGinStreamHander(protoFn, func(ctx context.Context, in streamItem) (events <-chan streamItem, err error) {
+			ch := make(chan streamItem)
+			go func() {
+				defer close(ch)
+				for i := 1; i <= in.Id; i++ {
+					ch <- streamItem{Id: i, Name: in.Name}
+				}
+			}()
+			return ch, nil
+		}))
+		engine.POST("/empty", NewGinStreamHander(protoFn, func(ctx context.Context, in streamItem) (events <-chan streamItem, err error) {
+			return nil, nil
+		}))
+		engine.POST("/fail", NewGinStreamHander(protoFn, func(ctx context.Context, in streamItem) (events <-chan streamItem, err error) {
+			return nil, NewCodeError("400100", "bad stream").WithHttpStatus(http.StatusBadRequest)
+		}))
+	})
+}
+
+// newStreamTestClient 请求 json 的流式客户端，middlewares 为空时只解析 code/message 信封
+func newStreamTestClient(url string, middlewares ...HandlerFuncResponseMessage) *ClientProtocol {
+	client := NewClientProtocol("POST", url)
+	client.SetLog(LogIgnore{})
+	client.SetHeader("Content-Type", ContentTypeJson)
+	if len(middlewares) == 0 {
+		middlewares = []HandlerFuncResponseMessage{ResponseMiddleCodeMessageForClient}
+	}
+	client.Response().AddMiddleware(middlewares...)
+	return client
+}
+
+func TestStream(t *testing.T) {
+	server := newStreamTestServer(t)
+	for _, accept := range []string{ContentTypeEventStream, ContentTypeNDJson} {
+		client := newStreamTestClient(server.URL+"/stream", ResponseMiddleLog, ResponseMiddleCodeMessageForClient)
+		client.SetHeader("Accept", accept)
+		it, err := StreamOf[streamItem](client, streamItem{Id: 3, Name: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		items := make([]streamItem, 0)
+		for it.Next() {
+			items = append(items, it.Value())
+		}
+		it.Close()
+		if it.Err() != nil {
+			t.Fatal(it.Err())
+		}
+		if len(items) != 3 || items[2].Id != 3 || items[2].Name != "x" {
+			t.Fatalf("%s: unexpected items %+v", accept, items)
+		}
+	}
+}
+
+func TestStreamWrapDo(t *testing.T) {
+	server := newStreamTestServer(t)
+	calls := 0
+	countDo := func(message *ResponseMessage) (err error) {
+		message.WrapDo(func(next DoFunc) DoFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				calls++
+				return next(req)
+			}
+		})
+		return message.Next()
+	}
+	client := newStreamTestClient(server.URL+"/stream", countDo, ResponseMiddleCodeMessageForClient)
+	it, err := StreamOf[streamItem](client, streamItem{Id: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if count != 3 || calls != 1 {
+		t.Fatalf("expected 3 items through 1 wrapped call, got %d items %d calls", count, calls)
+	}
+}
+
+func TestStreamResponseError(t *testing.T) {
+	server := newStreamTestServer(t)
+	client := newStreamTestClient(server.URL + "/fail")
+	it, err := StreamOf[streamItem](client, streamItem{Id: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	if it.Next() {
+		t.Fatal("expected no items")
+	}
+	var remoteErr *RemoteError
+	if !errors.As(it.Err(), &remoteErr) || remoteErr.Code != "400100" {
+		t.Fatalf("expected remote error 400100, got %v", it.Err())
+	}
+}
+
+func TestStreamNilChannel(t *testing.T) {
+	server := newStreamTestServer(t)
+	client := newStreamTestClient(server.URL + "/empty")
+	stream, err := client.Stream(streamItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var item streamItem
+	err = stream.Next(&item)
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package apihttpprotocol
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TransportConfig 客户端传输层配置，同一个 Transport 可以在多个 ClientProtocol 间共享以复用连接池
+type TransportConfig struct {
+	CAFile             string // 自定义CA证书(PEM)，为空时使用系统证书
+	CertFile           string // mTLS 客户端证书
+	KeyFile            string // mTLS 客户端私钥
+	ServerName         string
+	InsecureSkipVerify bool
+
+	Proxy string // 代理地址，为空时读取环境变量 HTTP_PROXY/HTTPS_PROXY，"direct" 表示不使用代理
+
+	DialTimeout           time.Duration // 默认5秒
+	KeepAlive             time.Duration // 默认30秒
+	TLSHandshakeTimeout   time.Duration // 默认10秒
+	ResponseHeaderTimeout time.Duration // 0 表示不限制
+	IdleConnTimeout       time.Duration // 默认90秒
+
+	MaxIdleConns        int // 默认2000
+	MaxIdleConnsPerHost int // 默认1000
+	MaxConnsPerHost     int // 0 表示不限制
+
+	DisableHTTP2 bool
+}
+
+// TLSConfig 根据配置生成 tls.Config，未配置证书相关参数时返回 nil
+func (c TransportConfig) TLSConfig() (tlsConfig *tls.Config, err error) {
+	if c.CAFile == "" && c.CertFile == "" && c.ServerName == "" && !c.InsecureSkipVerify {
+		return nil, nil
+	}
+	tlsConfig = &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		b, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("no valid certificate found in ca file:%s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "load client certificate:%s", c.CertFile)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// NewTransport 根据配置创建 http.Transport
+func NewTransport(config TransportConfig) (transport *http.Transport, err error) {
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.KeepAlive <= 0 {
+		config.KeepAlive = 30 * time.Second
+	}
+	if config.TLSHandshakeTimeout <= 0 {
+		config.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if config.IdleConnTimeout <= 0 {
+		config.IdleConnTimeout = 90 * time.Second
+	}
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = 2000
+	}
+	if config.MaxIdleConnsPerHost <= 0 {
+		config.MaxIdleConnsPerHost = 1000
+	}
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy := http.ProxyFromEnvironment
+	switch config.Proxy {
+	case "":
+	case "direct":
+		proxy = nil
+	default:
+		proxyUrl, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "parse proxy:%s", config.Proxy)
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+	transport = &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		ForceAttemptHTTP2:     !config.DisableHTTP2, // 自定义 TLSClientConfig 后需显式开启 HTTP/2
+	}
+	if config.DisableHTTP2 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport, nil
+}
+
+// RoundTripperFunc 函数形式的 http.RoundTripper，便于测试时注入
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var defaultTransport http.RoundTripper = sharedTransport
+
+// SetDefaultTransport 设置新建 ClientProtocol 默认使用的传输层，应在初始化阶段调用
+func SetDefaultTransport(transport http.RoundTripper) {
+	defaultTransport = transport
+}
+
+// SetTransport 设置当前客户端的传输层，传入同一个实例即可在多个客户端间共享连接池
+func (c *ClientProtocol) SetTransport(transport http.RoundTripper) *ClientProtocol {
+	client := *c.httpClient
+	client.Transport = transport
+	c.httpClient = &client
+	return c
+}
+
+// SetHttpClient 替换当前客户端使用的 http.Client
+func (c *ClientProtocol) SetHttpClient(client *http.Client) *ClientProtocol {
+	c.httpClient = client
+	return c
+}
+
+// SetTimeout 设置请求超时时间，0 表示不限制
+func (c *ClientProtocol) SetTimeout(timeout time.Duration) *ClientProtocol {
+	client := *c.httpClient
+	client.Timeout = timeout
+	c.httpClient = &client
+	return c
+}
@@ -0,0 +1,58 @@
+package apihttpprotocol
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeJson)
+		w.Write([]byte(`{"code":"0","message":"success","data":"tls"}`))
+	}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClientProtocol("GET", server.URL+"/tls")
+	client.SetLog(LogIgnore{})
+	client.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+	var out string
+	if err := client.Do(nil, &out); err == nil {
+		t.Fatal("default transport should not trust test certificate")
+	}
+
+	transport, err := NewTransport(TransportConfig{CAFile: caFile, Proxy: "direct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = NewClientProtocol("GET", server.URL+"/tls")
+	client.SetLog(LogIgnore{}).SetTransport(transport)
+	client.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+	if err := client.Do(nil, &out); err != nil || out != "tls" {
+		t.Fatalf("out:%s,err:%v", out, err)
+	}
+
+	client = NewClientProtocol("GET", "http://mock.local/ping")
+	client.SetLog(LogIgnore{}).SetTransport(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{ContentTypeJson}},
+			Body:       io.NopCloser(strings.NewReader(`{"code":"0","message":"success","data":"mock"}`)),
+			Request:    req,
+		}, nil
+	}))
+	client.Response().AddMiddleware(ResponseMiddleCodeMessageForClient)
+	if err := client.Do(nil, &out); err != nil || out != "mock" {
+		t.Fatalf("out:%s,err:%v", out, err)
+	}
+}